@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var errRampAbsorb = errors.New("a ramp cannot be applied while auto-started events are running")
+
+// autoStarts reports whether the event starts firing when the ACAP starts.
+func (e *EvaEvent) autoStarts() bool {
+	return e.AutoStart != nil && *e.AutoStart
+}
+
+// startAutoRun starts a simulation of only the enabled auto-start events when the ACAP starts,
+// unless a resumed simulation already runs. The run is not persisted for resuming: the next
+// start auto-starts the events again.
+func (eva *EvaApplication) startAutoRun() {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	if eva.run != nil || eva.stopping != nil {
+		return
+	}
+	count := 0
+	for _, ev := range eva.events {
+		if ev.autoStarts() && ev.IsEnabled() {
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	eva.timeScale.Store(math.Float64bits(1))
+	eva.rateCap.resetCounts()
+	eva.run = newSimulationRun(RunOptions{TimeScale: 1})
+	eva.run.autoStart = true
+	eva.StartEventSimulation()
+	eva.openRunRecord(eva.run, 1)
+	eva.acapp.Syslog.Infof("Auto-started %d event(s)", count)
+}
+
+// absorbAutoRun turns the running auto-start simulation into a full one with opts: the
+// auto-started events keep firing on their schedule and all other events join. The time scale
+// and duration apply to the whole run, the seed, phase offset and warm-up to the joining events. A ramp
+// is refused, it would change the running schedules. Caller must hold eva.mu.
+func (eva *EvaApplication) absorbAutoRun(opts RunOptions) error {
+	if opts.Ramp.duration > 0 {
+		return errRampAbsorb
+	}
+	run := eva.run
+	run.autoStart = false
+	run.noOffset = opts.NoOffset
+	run.seed = opts.Seed
+	if opts.Warmup > 0 {
+		run.warmupEnds = time.Now().Add(opts.Warmup)
+	}
+	eva.timeScale.Store(math.Float64bits(opts.TimeScale))
+	for _, ev := range eva.events {
+		if ev.autoStarts() && run.sched.scheduled(ev.ID) {
+			continue
+		}
+		ev.ResetState()
+		eva.startEventRun(ev)
+	}
+	if opts.Duration > 0 {
+		run.stopsAt = time.Now().Add(opts.Duration)
+		go eva.autoStop(run, opts.Duration)
+	}
+	eva.persistRun(runState(run, opts.TimeScale))
+	return nil
+}
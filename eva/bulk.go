@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// maxBulkEvents is the largest number of events a bulk request accepts.
+const maxBulkEvents = 500
+
+// BulkItem is the outcome for one event of a bulk create.
+type BulkItem struct {
+	Index             int           `json:"index"` // Position in the request
+	Name              string        `json:"name"`
+	ID                uint          `json:"id,omitempty"` // Set once created
+	Status            string        `json:"status"`       // "created", "invalid" or "skipped"
+	Error             string        `json:"error,omitempty"`
+	Fields            []*FieldError `json:"fields,omitempty"`
+	RegistrationError string        `json:"registration_error,omitempty"`
+	JoinedRun         bool          `json:"joined_run,omitempty"`
+}
+
+// BulkCreateResult is the response of a bulk create.
+type BulkCreateResult struct {
+	Created int        `json:"created"`
+	Failed  int        `json:"failed"`
+	Items   []BulkItem `json:"items"`
+}
+
+// validateBulk validates every event up front and fills in the defaults of single creates. It
+// returns the outcome per event and the indexes of the valid ones.
+func validateBulk(events []EvaEvent) ([]BulkItem, []int) {
+	items := make([]BulkItem, len(events))
+	var valid []int
+	for i := range events {
+		ev := &events[i]
+		items[i] = BulkItem{Index: i, Name: ev.Name}
+		ev.Model = gorm.Model{}
+		if err := ev.Validate(); err != nil {
+			items[i].Status = "invalid"
+			items[i].Error = err.Error()
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				items[i].Fields = validationErr.Errors
+			}
+			continue
+		}
+		if ev.Enabled == nil {
+			ev.Enabled = boolPtr(true)
+		}
+		valid = append(valid, i)
+	}
+	return items, valid
+}
+
+// errTooManyBulkEvents rejects bulk requests with more than maxBulkEvents events.
+var errTooManyBulkEvents = fmt.Errorf("at most %d events per request", maxBulkEvents)
+
+// createBulk inserts the valid events in one transaction, then registers them and lets them join
+// a running simulation like single creates. Without continueOnError a single invalid event
+// creates nothing and the valid ones are reported as skipped.
+func (eva *EvaApplication) createBulk(events []EvaEvent, continueOnError bool) (BulkCreateResult, error) {
+	items, valid := validateBulk(events)
+	result := BulkCreateResult{Failed: len(events) - len(valid), Items: items}
+	if result.Failed > 0 && !continueOnError {
+		for _, i := range valid {
+			result.Items[i].Status = "skipped"
+		}
+		return result, nil
+	}
+	if len(valid) == 0 {
+		return result, nil
+	}
+	err := eva.db.Transaction(func(tx *gorm.DB) error {
+		for _, i := range valid {
+			if err := tx.Create(&events[i]).Error; err != nil {
+				return fmt.Errorf("event %d (%s): %w", i, events[i].Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return BulkCreateResult{}, err
+	}
+
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	for _, i := range valid {
+		ev := &events[i]
+		item := &result.Items[i]
+		item.ID = ev.ID
+		item.Status = "created"
+		result.Created++
+		eva.events = append(eva.events, ev)
+		if err := eva.registerEvent(ev); err != nil {
+			eva.acapp.Syslog.Critf("Failed to register new event %s: %v", ev.Name, err)
+			item.RegistrationError = err.Error()
+			continue
+		}
+		item.JoinedRun = eva.run != nil && eva.startEventRun(ev)
+	}
+	eva.acapp.Syslog.Infof("Created %d events in bulk", result.Created)
+	return result, nil
+}
+
+// BulkDeleteRequest is the body of a bulk delete.
+type BulkDeleteRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// UnregisterFailure is an event of a bulk delete the platform failed to undeclare.
+type UnregisterFailure struct {
+	ID    uint   `json:"id"`
+	Error string `json:"error"`
+}
+
+// BulkDeleteResult is the response of a bulk delete.
+type BulkDeleteResult struct {
+	Deleted          []uint              `json:"deleted"`
+	NotFound         []uint              `json:"not_found"`
+	UnregisterFailed []UnregisterFailure `json:"unregister_failed"` // Deleted all the same
+}
+
+// deleteBulk deletes the events with the given IDs like single deletes: their jobs and firing
+// stop, they are unregistered from the platform and removed from memory, and the rows are
+// deleted in one transaction. An event the platform failed to undeclare is deleted all the same,
+// so a broken declaration cannot keep it around, and reported.
+func (eva *EvaApplication) deleteBulk(ids []uint) (BulkDeleteResult, error) {
+	result := BulkDeleteResult{Deleted: []uint{}, NotFound: []uint{}, UnregisterFailed: []UnregisterFailure{}}
+	var found []uint
+	if len(ids) > 0 {
+		if err := eva.db.Model(&EvaEvent{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+			return result, err
+		}
+	}
+	exists := map[uint]bool{}
+	for _, id := range found {
+		exists[id] = true
+	}
+	seen := map[uint]bool{}
+	for _, id := range ids {
+		if !exists[id] && !seen[id] {
+			result.NotFound = append(result.NotFound, id)
+		}
+		seen[id] = true
+	}
+	if len(found) == 0 {
+		return result, nil
+	}
+
+	for _, id := range found {
+		eva.cancelTriggerJobs(id)
+		// Stop firing before the declaration goes away.
+		eva.stopEventRun(id)
+	}
+	eva.mu.Lock()
+	for _, id := range found {
+		if registered := eva.findRegisteredEvent(id); registered != nil {
+			if err := eva.unregisterEvent(registered); err != nil {
+				result.UnregisterFailed = append(result.UnregisterFailed, UnregisterFailure{ID: id, Error: err.Error()})
+			}
+			eva.removeRegisteredEvent(id)
+		}
+	}
+	eva.mu.Unlock()
+
+	if err := eva.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Delete(&EvaEvent{}, found).Error
+	}); err != nil {
+		return result, err
+	}
+	result.Deleted = found
+	eva.acapp.Syslog.Infof("Deleted %d events in bulk", len(found))
+	return result, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	maxChaosDuration   = 24 * time.Hour
+	minChaosIntervalMs = 10
+)
+
+var errChaosRunning = errors.New("chaos run already running")
+
+// ChaosRequest configures a chaos run.
+type ChaosRequest struct {
+	DurationSeconds float64 `json:"duration_seconds"`
+	MinIntervalMs   int     `json:"min_interval_ms"`
+	MaxIntervalMs   int     `json:"max_interval_ms"`
+	EventIDs        []uint  `json:"event_ids"` // Events to pick from, all enabled registered events when empty
+}
+
+// ChaosCount is the number of fires of one event in a chaos run.
+type ChaosCount struct {
+	EventID uint   `json:"event_id"`
+	Name    string `json:"event"`
+	Fired   int    `json:"fired"`
+}
+
+// ChaosResult summarizes a running or finished chaos run.
+type ChaosResult struct {
+	StartedAt time.Time    `json:"started_at"`
+	EndedAt   time.Time    `json:"ended_at,omitzero"`
+	Running   bool         `json:"running"`
+	Cancelled bool         `json:"cancelled"`
+	Fired     int          `json:"fired"`
+	Failed    int          `json:"failed"`
+	Events    []ChaosCount `json:"events"`
+}
+
+// chaosRun is a running or finished chaos run.
+type chaosRun struct {
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the run ended
+	mu     sync.Mutex
+	result ChaosResult
+	counts map[uint]*ChaosCount
+}
+
+// Validate checks the duration and interval range of the request.
+func (r *ChaosRequest) Validate() error {
+	if r.DurationSeconds <= 0 || time.Duration(r.DurationSeconds*float64(time.Second)) > maxChaosDuration {
+		return fmt.Errorf("duration_seconds must be greater than 0 and at most %.0f", maxChaosDuration.Seconds())
+	}
+	if r.MinIntervalMs < minChaosIntervalMs {
+		return fmt.Errorf("min_interval_ms must be at least %d", minChaosIntervalMs)
+	}
+	if r.MaxIntervalMs < r.MinIntervalMs {
+		return fmt.Errorf("max_interval_ms must not be less than min_interval_ms")
+	}
+	return nil
+}
+
+// startChaos starts a chaos run in the background. It runs alongside the simulation but not
+// alongside another chaos run.
+func (eva *EvaApplication) startChaos(req ChaosRequest) (*chaosRun, error) {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	if eva.chaosRunning() {
+		return nil, errChaosRunning
+	}
+	for _, id := range req.EventIDs {
+		if ev := eva.findRegisteredEvent(id); ev == nil || ev.EventId == 0 {
+			return nil, fmt.Errorf("event %d is not registered", id)
+		}
+	}
+	if len(req.EventIDs) == 0 && len(eva.chaosCandidates(nil)) == 0 {
+		return nil, errNoEvents
+	}
+
+	duration := time.Duration(req.DurationSeconds * float64(time.Second))
+	ctx, cancel := context.WithTimeout(eva.appCtx, duration)
+	run := &chaosRun{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		result: ChaosResult{StartedAt: time.Now(), Running: true},
+		counts: map[uint]*ChaosCount{},
+	}
+	eva.chaos = run
+	eva.playbackWg.Add(1)
+	go func() {
+		defer eva.playbackWg.Done()
+		defer close(run.done)
+		defer cancel()
+		eva.runChaos(ctx, run, req)
+	}()
+	eva.acapp.Syslog.Infof("Chaos run started for %s, fires every %d-%d ms", duration, req.MinIntervalMs, req.MaxIntervalMs)
+	return run, nil
+}
+
+// runChaos fires a random event after each random gap until ctx ends.
+func (eva *EvaApplication) runChaos(ctx context.Context, run *chaosRun, req ChaosRequest) {
+	gap := func() time.Duration {
+		return time.Duration(RandomIntInRange(GlobalRand, req.MinIntervalMs, req.MaxIntervalMs)) * time.Millisecond
+	}
+	timer := time.NewTimer(gap())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			run.finish(errors.Is(ctx.Err(), context.Canceled))
+			result := run.Result()
+			eva.acapp.Syslog.Infof("Chaos run ended: %d fires, %d failed", result.Fired, result.Failed)
+			return
+		case <-timer.C:
+		}
+		eva.chaosFire(run, req.EventIDs)
+		timer.Reset(gap())
+	}
+}
+
+// chaosFire triggers one randomly chosen event of ids, or of all enabled registered events
+// without ids.
+func (eva *EvaApplication) chaosFire(run *chaosRun, ids []uint) {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	candidates := eva.chaosCandidates(ids)
+	if len(candidates) == 0 {
+		return
+	}
+	ev := candidates[GlobalRand.Intn(len(candidates))]
+	err := eva.triggerEvent(ev, sendOptions{})
+	run.record(ev, err)
+	if err == nil {
+		eva.scheduleFollowUps(ev, 0)
+	}
+}
+
+// chaosCandidates returns the registered events of ids, or all enabled registered events without
+// ids. Caller must hold eva.mu.
+func (eva *EvaApplication) chaosCandidates(ids []uint) []*EvaEvent {
+	var candidates []*EvaEvent
+	for _, ev := range eva.events {
+		if ev.EventId == 0 {
+			continue
+		}
+		if (len(ids) == 0 && ev.IsEnabled()) || slices.Contains(ids, ev.ID) {
+			candidates = append(candidates, ev)
+		}
+	}
+	return candidates
+}
+
+// chaosRunning reports whether a chaos run is active. Caller must hold eva.mu.
+func (eva *EvaApplication) chaosRunning() bool {
+	if eva.chaos == nil {
+		return false
+	}
+	select {
+	case <-eva.chaos.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stopChaos cancels the active chaos run and waits for it. It returns false when none runs.
+func (eva *EvaApplication) stopChaos() bool {
+	eva.mu.Lock()
+	run := eva.chaos
+	running := eva.chaosRunning()
+	eva.mu.Unlock()
+	if !running {
+		return false
+	}
+	run.cancel()
+	<-run.done
+	return true
+}
+
+// record counts a fire of ev.
+func (r *chaosRun) record(ev *EvaEvent, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.result.Failed++
+		return
+	}
+	r.result.Fired++
+	count, ok := r.counts[ev.ID]
+	if !ok {
+		count = &ChaosCount{EventID: ev.ID, Name: ev.Name}
+		r.counts[ev.ID] = count
+	}
+	count.Fired++
+}
+
+// finish marks the run as ended.
+func (r *chaosRun) finish(cancelled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Running = false
+	r.result.Cancelled = cancelled
+	r.result.EndedAt = time.Now()
+}
+
+// Result returns the summary so far with the fires per event ordered by event ID.
+func (r *chaosRun) Result() ChaosResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := r.result
+	result.Events = make([]ChaosCount, 0, len(r.counts))
+	for _, count := range r.counts {
+		result.Events = append(result.Events, *count)
+	}
+	slices.SortFunc(result.Events, func(a, b ChaosCount) int { return int(a.EventID) - int(b.EventID) })
+	return result
+}
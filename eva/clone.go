@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// CloneRequest is the optional body of a clone.
+type CloneRequest struct {
+	Name string `json:"name"` // Name of the copy, the original's with " (copy)" when empty
+}
+
+// copyEvent returns a deep copy of the stored settings of the event, without its ID and
+// runtime state.
+func (e *EvaEvent) copyEvent() (EvaEvent, error) {
+	var c EvaEvent
+	data, err := json.Marshal(e)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	c.Model = gorm.Model{}
+	return c, nil
+}
+
+// takenEventNames returns the sanitized names of all stored events, the names they are
+// declared under on the platform.
+func (eva *EvaApplication) takenEventNames() (map[string]bool, error) {
+	var names []string
+	if err := eva.db.Model(&EvaEvent{}).Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	for _, name := range names {
+		taken[sanitizeEventName(name)] = true
+	}
+	return taken, nil
+}
+
+// uniqueEventName returns name, or name with the first numeric suffix from 2 on whose
+// sanitized form is not taken.
+func uniqueEventName(name string, taken map[string]bool) string {
+	candidate := name
+	for i := 2; taken[sanitizeEventName(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s %d", name, i)
+	}
+	return candidate
+}
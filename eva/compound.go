@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/Cacsjep/goxis/pkg/axevent"
+	"github.com/Cacsjep/goxis/pkg/utils"
+)
+
+// Compound field types expand into several platform keys named <key>_<part>.
+
+// compoundValue holds the generated values of a compound field, keyed by part name.
+type compoundValue map[string]interface{}
+
+// compoundParts returns the part names of a compound field in declaration order, or nil.
+func (d *DataFields) compoundParts() []string {
+	switch d.ValueType {
+	case GeoType:
+		return []string{"lat", "lon"}
+	case BBoxType:
+		return []string{"left", "top", "right", "bottom"}
+	}
+	return nil
+}
+
+// compoundEntries declares one double entry per part, initialised with the field's static value.
+func (d *DataFields) compoundEntries() []*acapapp.EventEntry {
+	static := d.compoundStatic()
+	entries := []*acapapp.EventEntry{}
+	for _, part := range d.compoundParts() {
+		isData := true
+		entries = append(entries, &acapapp.EventEntry{
+			Key:         d.SanitizedKey() + "_" + part,
+			Value:       static[part],
+			ValueType:   axevent.AXValueTypeDouble,
+			KeyNiceName: utils.StrPtr(d.Name + " " + part),
+			IsData:      &isData,
+		})
+	}
+	return entries
+}
+
+// compoundStatic is the value of a compound field without randomization.
+func (d *DataFields) compoundStatic() compoundValue {
+	switch d.ValueType {
+	case GeoType:
+		return compoundValue{"lat": (d.LatMin + d.LatMax) / 2, "lon": (d.LonMin + d.LonMax) / 2}
+	case BBoxType:
+		minSize, maxSize := d.boxSizes()
+		size := (minSize + maxSize) / 2
+		return box{left: (1 - size) / 2, top: (1 - size) / 2, width: size, height: size}.value()
+	}
+	return compoundValue{}
+}
+
+// validateCompound checks the settings specific to compound field types and returns a message
+// describing the first problem, or "".
+func (d *DataFields) validateCompound() string {
+	switch d.ValueType {
+	case GeoType:
+		if d.LatMin > d.LatMax || d.LonMin > d.LonMax {
+			return "lat_min/lon_min must not exceed lat_max/lon_max"
+		}
+		if d.LatMin < -90 || d.LatMax > 90 || d.LonMin < -180 || d.LonMax > 180 {
+			return "bounding box must lie within lat [-90,90] and lon [-180,180]"
+		}
+		if d.Mode != "" && d.Mode != RandomWalkMode {
+			return fmt.Sprintf("geo fields only support the %s mode", RandomWalkMode)
+		}
+	case BBoxType:
+		minSize, maxSize := d.boxSizes()
+		if minSize <= 0 || minSize > maxSize || maxSize > 1 {
+			return "box sizes must satisfy 0 < box_min_size <= box_max_size <= 1"
+		}
+		if d.Mode != "" && d.Mode != RandomWalkMode {
+			return fmt.Sprintf("bbox fields only support the %s mode", RandomWalkMode)
+		}
+	}
+	return ""
+}
+
+// nextGeo returns a random point inside the bounding box, or with random_walk a point that
+// moved at most WalkMaxDelta degrees per axis from the previous one. Caller must hold eventState.mu.
+func (fs *fieldState) nextGeo(r RandSource, field *DataFields) compoundValue {
+	if field.Mode == RandomWalkMode && fs.walkUsed {
+		fs.lat = clamp(fs.lat+RandomFloatInRange(r, -field.WalkMaxDelta, field.WalkMaxDelta), field.LatMin, field.LatMax)
+		fs.lon = clamp(fs.lon+RandomFloatInRange(r, -field.WalkMaxDelta, field.WalkMaxDelta), field.LonMin, field.LonMax)
+	} else {
+		fs.lat = RandomFloatInRange(r, field.LatMin, field.LatMax)
+		fs.lon = RandomFloatInRange(r, field.LonMin, field.LonMax)
+		fs.walkUsed = true
+	}
+	return compoundValue{"lat": fs.lat, "lon": fs.lon}
+}
+
+// box is a normalized bounding box; right and bottom are left+width and top+height.
+type box struct {
+	left, top, width, height float64
+}
+
+func (b box) value() compoundValue {
+	return compoundValue{"left": b.left, "top": b.top, "right": b.left + b.width, "bottom": b.top + b.height}
+}
+
+// boxSizes returns the minimum and maximum edge length of a bbox field as a fraction of the
+// frame, defaulting to 0.05 and 0.5.
+func (d *DataFields) boxSizes() (float64, float64) {
+	minSize, maxSize := d.BoxMinSize, d.BoxMaxSize
+	if minSize == 0 {
+		minSize = 0.05
+	}
+	if maxSize == 0 {
+		maxSize = max(0.5, minSize)
+	}
+	return minSize, maxSize
+}
+
+// nextBox returns a random box inside the frame, or with random_walk the previous box moved by
+// at most WalkMaxDelta per axis, keeping its size. Caller must hold eventState.mu.
+func (fs *fieldState) nextBox(r RandSource, field *DataFields) compoundValue {
+	if field.Mode == RandomWalkMode && fs.walkUsed {
+		fs.box.left = clamp(fs.box.left+RandomFloatInRange(r, -field.WalkMaxDelta, field.WalkMaxDelta), 0, 1-fs.box.width)
+		fs.box.top = clamp(fs.box.top+RandomFloatInRange(r, -field.WalkMaxDelta, field.WalkMaxDelta), 0, 1-fs.box.height)
+	} else {
+		minSize, maxSize := field.boxSizes()
+		fs.box.width = RandomFloatInRange(r, minSize, maxSize)
+		fs.box.height = RandomFloatInRange(r, minSize, maxSize)
+		fs.box.left = RandomFloatInRange(r, 0, 1-fs.box.width)
+		fs.box.top = RandomFloatInRange(r, 0, 1-fs.box.height)
+		fs.walkUsed = true
+	}
+	return fs.box.value()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConditionOperator compares the referenced field's generated value with a Condition's value.
+type ConditionOperator string
+
+const (
+	ConditionEq  ConditionOperator = "eq"
+	ConditionNe  ConditionOperator = "ne"
+	ConditionGt  ConditionOperator = "gt"
+	ConditionGte ConditionOperator = "gte"
+	ConditionLt  ConditionOperator = "lt"
+	ConditionLte ConditionOperator = "lte"
+)
+
+// FieldCondition includes a field in a payload only when another field's value matches.
+// Field is the referenced field's name or key.
+type FieldCondition struct {
+	Field    string            `json:"field"`
+	Operator ConditionOperator `json:"operator"`
+	Value    interface{}       `json:"value"`
+}
+
+// ref returns the sanitized key of the referenced field.
+func (c *FieldCondition) ref() string {
+	return strings.ToLower(sanitizeEventName(c.Field))
+}
+
+// validate checks the condition on its own; the referenced field is resolved by fieldOrder.
+func (c *FieldCondition) validate() error {
+	if strings.TrimSpace(c.Field) == "" {
+		return fmt.Errorf("condition field must not be empty")
+	}
+	switch c.Operator {
+	case ConditionEq, ConditionNe:
+	case ConditionGt, ConditionGte, ConditionLt, ConditionLte:
+		if _, err := toFloat(c.Value); err != nil {
+			return fmt.Errorf("condition operator %s requires a numeric value", c.Operator)
+		}
+	default:
+		return fmt.Errorf("unknown condition operator %q", c.Operator)
+	}
+	return nil
+}
+
+// met reports whether the condition holds for the values generated so far. A referenced field
+// that was omitted from the payload never satisfies a condition.
+func (c *FieldCondition) met(values map[string]interface{}) bool {
+	actual := lookupValue(values, c.ref())
+	if actual == nil {
+		return false
+	}
+	switch c.Operator {
+	case ConditionEq, ConditionNe:
+		equal := fmt.Sprintf("%v", actual) == fmt.Sprintf("%v", c.Value)
+		if a, err := toFloat(actual); err == nil {
+			if b, err := toFloat(c.Value); err == nil {
+				equal = a == b
+			}
+		}
+		return equal == (c.Operator == ConditionEq)
+	}
+	a, err := toFloat(actual)
+	if err != nil {
+		return false
+	}
+	b, err := toFloat(c.Value)
+	if err != nil {
+		return false
+	}
+	switch c.Operator {
+	case ConditionGt:
+		return a > b
+	case ConditionGte:
+		return a >= b
+	case ConditionLt:
+		return a < b
+	case ConditionLte:
+		return a <= b
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron spec: minute, hour, day of month, month and day of week.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // Bit i is set when value i matches
+	domAny, dowAny                bool   // The day fields were "*"
+}
+
+// cronField describes the range and names of one cron field.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // Names for min, min+1, ...
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	// 7 is accepted as Sunday as well.
+	{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+// parseCron parses a cron spec like "0 8,17 * * MON-FRI". Each field accepts "*", values,
+// ranges "a-b", lists "a,b" and steps "*/n" or "a-b/n"; months and weekdays also accept names.
+func parseCron(spec string) (*cronSchedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(parts))
+	}
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		b, err := cronFields[i].parse(part)
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+	s := &cronSchedule{minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4], domAny: parts[2] == "*", dowAny: parts[4] == "*"}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parse parses one field into a bit set.
+func (f *cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepStr)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(loStr); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiStr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: range %s is inverted", f.name, rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name of the field.
+func (f *cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: %q is not between %d and %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// dayMatches applies the cron rule that a restricted day of month and day of week match either way.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first matching minute after t, or the zero time if none matches within
+// five years (e.g. "0 0 30 2 *").
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// CronStatus reports the next scheduled fire of a cron event.
+type CronStatus struct {
+	EventID  uint      `json:"event_id"`
+	Name     string    `json:"event"`
+	NextFire time.Time `json:"next_fire"`
+}
+
+// setNextFire records the next scheduled cron fire.
+func (e *EvaEvent) setNextFire(next time.Time) {
+	e.state.mu.Lock()
+	e.state.nextFire = next
+	e.state.mu.Unlock()
+}
+
+// CronStatus returns the next scheduled fire of the event, false when no cron run is active.
+func (e *EvaEvent) CronStatus() (CronStatus, bool) {
+	if e.state == nil {
+		return CronStatus{}, false
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	if e.state.nextFire.IsZero() {
+		return CronStatus{}, false
+	}
+	return CronStatus{EventID: e.ID, Name: e.Name, NextFire: e.state.nextFire}, true
+}
+
+// cronJob fires ev at every time matching its CronSpec, nil when the spec is invalid.
+// Times passing while the event is busy with a scheduled fall are skipped.
+func (eva *EvaApplication) cronJob(run *simulationRun, ev *EvaEvent) *simJob {
+	schedule, err := parseCron(ev.CronSpec)
+	if err != nil {
+		// Validation rejects this on save.
+		eva.acapp.Syslog.Critf("Invalid cron_spec of %s: %v", ev.Name, err)
+		return nil
+	}
+	first := schedule.next(run.firstFireFrom())
+	if first.IsZero() {
+		return nil
+	}
+	ev.setNextFire(first)
+	return &simJob{
+		eventID: ev.ID,
+		at:      first,
+		fire: func(time.Time) (time.Time, bool) {
+			done, busyUntil := eva.simulateFire(run, ev)
+			if done {
+				return time.Time{}, false
+			}
+			next := schedule.next(later(busyUntil, time.Now()))
+			if next.IsZero() {
+				return time.Time{}, false
+			}
+			ev.setNextFire(next)
+			return next, true
+		},
+		stop: func() { ev.setNextFire(time.Time{}) },
+	}
+}
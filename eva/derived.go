@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DerivedOp combines the values of a derived field's sources.
+type DerivedOp string
+
+const (
+	// DerivedSum adds all sources and the constant.
+	DerivedSum DerivedOp = "sum"
+	// DerivedDiff subtracts the remaining sources and the constant from the first source.
+	DerivedDiff DerivedOp = "diff"
+	// DerivedRatio divides the first source by the second source, or by the constant.
+	DerivedRatio DerivedOp = "ratio"
+	// DerivedPercent is DerivedRatio times 100.
+	DerivedPercent DerivedOp = "percent"
+)
+
+// derivedRefs returns the sanitized keys of the derived field's sources.
+func (d *DataFields) derivedRefs() []string {
+	if d.Mode != DerivedMode {
+		return nil
+	}
+	refs := make([]string, len(d.DerivedSources))
+	for i, source := range d.DerivedSources {
+		refs[i] = strings.ToLower(sanitizeEventName(source))
+	}
+	return refs
+}
+
+// validateDerived checks the operation and its operands; the sources are resolved by fieldOrder.
+func (d *DataFields) validateDerived() error {
+	if len(d.DerivedSources) == 0 {
+		return fmt.Errorf("derived fields require derived_sources")
+	}
+	switch d.DerivedOp {
+	case DerivedSum, DerivedDiff:
+	case DerivedRatio, DerivedPercent:
+		operands := len(d.DerivedSources)
+		if d.DerivedConstant != nil {
+			operands++
+		}
+		if operands != 2 {
+			return fmt.Errorf("%s needs two sources, or one source and derived_constant", d.DerivedOp)
+		}
+		if d.DerivedConstant != nil && *d.DerivedConstant == 0 {
+			return fmt.Errorf("derived_constant must not be 0 for %s", d.DerivedOp)
+		}
+	default:
+		return fmt.Errorf("derived_op must be one of %s, %s, %s or %s", DerivedSum, DerivedDiff, DerivedRatio, DerivedPercent)
+	}
+	return nil
+}
+
+// derivedValue applies the derived operation to the already generated source values.
+func (d *DataFields) derivedValue(values map[string]interface{}) (float64, error) {
+	operands := make([]float64, 0, len(d.DerivedSources)+1)
+	for _, ref := range d.derivedRefs() {
+		v, err := toFloat(lookupValue(values, ref))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", ref, err)
+		}
+		operands = append(operands, v)
+	}
+	if d.DerivedConstant != nil {
+		operands = append(operands, *d.DerivedConstant)
+	}
+
+	switch d.DerivedOp {
+	case DerivedSum, DerivedDiff:
+		result := operands[0]
+		for _, v := range operands[1:] {
+			if d.DerivedOp == DerivedSum {
+				result += v
+			} else {
+				result -= v
+			}
+		}
+		return result, nil
+	case DerivedRatio, DerivedPercent:
+		if operands[1] == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		result := operands[0] / operands[1]
+		if d.DerivedOp == DerivedPercent {
+			result *= 100
+		}
+		return result, nil
+	}
+	return 0, fmt.Errorf("unknown derived_op %q", d.DerivedOp)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	defaultDrainTimeout  = 2 * time.Second
+	shutdownDrainTimeout = 500 * time.Millisecond
+	maxDrainTimeout      = time.Minute
+	drainPoll            = 10 * time.Millisecond
+)
+
+// DrainReport tells how a stopped simulation run wound down.
+type DrainReport struct {
+	Completed int  `json:"completed"` // Sends that finished during the drain, e.g. a fire in flight or a pending fall
+	Abandoned int  `json:"abandoned"` // Pending sends dropped, e.g. follow-ups or falls not due before the timeout
+	TimedOut  bool `json:"timed_out"` // The drain timeout ended the drain before all pending sends finished
+}
+
+// parseDrainTimeout reads the "drain_timeout_ms" query parameter of a simulation stop.
+func parseDrainTimeout(c fiber.Ctx) (time.Duration, error) {
+	q := c.Query("drain_timeout_ms")
+	if q == "" {
+		return defaultDrainTimeout, nil
+	}
+	ms := fiber.Query[int](c, "drain_timeout_ms", -1)
+	if ms < 0 || time.Duration(ms)*time.Millisecond > maxDrainTimeout {
+		return 0, fmt.Errorf("drain_timeout_ms must be between 0 and %d", maxDrainTimeout.Milliseconds())
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// drainRun winds run down: follow-ups and repeated triggers are dropped right away, no new fires
+// are armed, and the fire in flight, pending falls and pulses and sends delayed by the rate cap
+// get up to timeout to finish before the run is cancelled.
+func (eva *EvaApplication) drainRun(run *simulationRun, timeout time.Duration) DrainReport {
+	var report DrainReport
+	report.Abandoned = eva.cancelFollowUps() + eva.repeats.stopAll()
+	pulses := len(eva.PendingPulses())
+	delayed := eva.pendingDelayedSends()
+	run.sched.drain()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(drainPoll)
+	defer poll.Stop()
+	for !report.TimedOut && !eva.drained(run) {
+		select {
+		case <-deadline.C:
+			report.TimedOut = true
+		case <-poll.C:
+		}
+	}
+	left := eva.cancelAllPulses()
+	leftDelayed := eva.cancelDelayedSends()
+	run.stop()
+	fired, dropped := run.sched.drainResult()
+	report.Completed = fired + max(pulses-left, 0) + max(delayed-leftDelayed, 0)
+	report.Abandoned += dropped + left + leftDelayed
+	return report
+}
+
+// drained reports whether the draining run fired all remaining jobs and no pulse or delayed
+// send is pending.
+func (eva *EvaApplication) drained(run *simulationRun) bool {
+	select {
+	case <-run.sched.idle:
+		return len(eva.PendingPulses()) == 0 && eva.pendingDelayedSends() == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"time"
+)
+
+// DutyPhase is the current phase of a duty cycle.
+type DutyPhase string
+
+const (
+	PhaseActive   DutyPhase = "active"
+	PhaseInactive DutyPhase = "inactive"
+)
+
+// DutyCycleStatus reports the current phase of a duty cycle event.
+type DutyCycleStatus struct {
+	EventID uint      `json:"event_id"`
+	Name    string    `json:"event"`
+	Phase   DutyPhase `json:"phase"`
+	EndsAt  time.Time `json:"ends_at"`
+}
+
+// hasDutyCycle reports whether the event alternates between ActiveSeconds and InactiveSeconds
+// instead of firing on its interval.
+func (e *EvaEvent) hasDutyCycle() bool {
+	return e.ActiveSeconds > 0 || e.InactiveSeconds > 0
+}
+
+// validateDutyCycle checks the duty cycle settings of the event.
+func (e *EvaEvent) validateDutyCycle() []*FieldError {
+	if !e.hasDutyCycle() {
+		if e.ActiveSeconds < 0 || e.InactiveSeconds < 0 {
+			return []*FieldError{{Message: "active_seconds and inactive_seconds must not be negative"}}
+		}
+		return nil
+	}
+	var errs []*FieldError
+	if e.ActiveSeconds <= 0 || e.InactiveSeconds <= 0 {
+		errs = append(errs, &FieldError{Message: "a duty cycle requires both active_seconds and inactive_seconds to be greater than 0"})
+	}
+	if !e.IsStateful() || e.stateKey() == "" {
+		errs = append(errs, &FieldError{Message: "a duty cycle requires a stateful event with a bool state field"})
+	}
+	if e.PulseDurationMs > 0 || e.ActiveDurationSeconds > 0 {
+		errs = append(errs, &FieldError{Message: "a duty cycle cannot be combined with pulse_duration_ms or active_duration_seconds"})
+	}
+	return errs
+}
+
+// setPhase records the current duty cycle phase.
+func (e *EvaEvent) setPhase(phase DutyPhase, endsAt time.Time) {
+	e.state.mu.Lock()
+	e.state.phase = phase
+	e.state.phaseEnds = endsAt
+	e.state.mu.Unlock()
+}
+
+// DutyCycleStatus returns the current phase of the event, false outside of a duty cycle run.
+func (e *EvaEvent) DutyCycleStatus() (DutyCycleStatus, bool) {
+	if e.state == nil {
+		return DutyCycleStatus{}, false
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	if e.state.phase == "" {
+		return DutyCycleStatus{}, false
+	}
+	return DutyCycleStatus{EventID: e.ID, Name: e.Name, Phase: e.state.phase, EndsAt: e.state.phaseEnds}, true
+}
+
+// dutyCycleJob alternates ev between its active and inactive phase, sending true at the start
+// of the active phase and false at its end. The first phase starts right away. Each phase ends
+// a phase duration after the previous one was due, not after its send completed, so send latency
+// does not stretch the cycle over long runs. A phase that ends behind the clock by less than its
+// duration is caught up right away; one further behind resumes from now instead of catching up
+// in a burst.
+func (eva *EvaApplication) dutyCycleJob(run *simulationRun, ev *EvaEvent) *simJob {
+	phases := []struct {
+		phase    DutyPhase
+		duration time.Duration
+	}{
+		{PhaseActive, eva.scaled(time.Duration(ev.ActiveSeconds) * time.Second)},
+		{PhaseInactive, eva.scaled(time.Duration(ev.InactiveSeconds) * time.Second)},
+	}
+	i := 0
+	var opts sendOptions
+	held := false
+	return &simJob{
+		eventID: ev.ID,
+		at:      run.firstFireFrom(),
+		fire: func(at time.Time) (time.Time, bool) {
+			p := phases[i]
+			if p.phase == PhaseActive {
+				// Both phases of a cycle use the same source values.
+				opts = sendOptions{sources: ev.pickSources()}
+			}
+			// Quiet hours and an unmet fire condition hold the rise of a cycle and with it its
+			// fall, a cycle that rose before they began still falls.
+			if p.phase == PhaseActive {
+				held = eva.isQuiet(ev, time.Now())
+				if held {
+					ev.recordQuiet()
+				} else if held = !eva.fireConditionMet(ev); held {
+					ev.recordUnmet()
+				}
+			}
+			// While paused the phases keep alternating without sending.
+			if !held && !eva.isPaused() {
+				eva.sendState(ev, p.phase == PhaseActive, opts)
+				// A cycle counts as one trigger, completed once its fall was sent.
+				if p.phase == PhaseInactive && ev.recordFire() {
+					return time.Time{}, false
+				}
+			}
+			ends := at.Add(p.duration)
+			if now := run.sched.now(); now.Sub(ends) > p.duration {
+				ends = now
+			}
+			ev.setPhase(p.phase, ends)
+			i = (i + 1) % len(phases)
+			return ends, true
+		},
+		stop: func() { ev.setPhase("", time.Time{}) },
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Element fields carry a raw XML fragment, e.g. a scene description. The value may reference
+// other fields of the event with template actions ({{.ObjectType}}), which are filled in when
+// the event fires.
+
+// elementRefs returns the sanitized keys referenced by the element's XML template.
+func (d *DataFields) elementRefs() ([]string, error) {
+	if d.ValueType != ElementType {
+		return nil, nil
+	}
+	return templateKeys(d.Name, d.elementXML())
+}
+
+// elementXML is the stored XML fragment of an element field.
+func (d *DataFields) elementXML() string {
+	s, _ := d.staticValue().(string)
+	return s
+}
+
+// elementValue renders the element's XML template against the generated values.
+// The raw XML is sent when rendering fails.
+func (d *DataFields) elementValue(values map[string]interface{}) string {
+	out, err := renderTemplate(d.Name, d.elementXML(), values)
+	if err != nil {
+		return d.elementXML()
+	}
+	return out
+}
+
+// validateElement checks that the element's value is a single well-formed XML element.
+func (d *DataFields) validateElement() error {
+	decoder := xml.NewDecoder(strings.NewReader(d.elementXML()))
+	depth, roots := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && strings.TrimSpace(string(t)) != "" {
+				return errors.New("text outside of the root element")
+			}
+		}
+	}
+	if roots != 1 {
+		return errors.New("value must contain exactly one root element")
+	}
+	return nil
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Cacsjep/goxis/pkg/acapapp"
@@ -18,23 +23,56 @@ import (
 
 // EvaApplication represents the main application structure.
 type EvaApplication struct {
-	acapp      acapapp.AcapApplication
-	webserver  *fiber.App
-	db         *gorm.DB
-	events     []*EvaEvent
-	mu         sync.Mutex
-	wg         sync.WaitGroup
-	ctx        context.Context
-	cancel     context.CancelFunc
-	simRunning bool
+	acapp        acapapp.AcapApplication
+	webserver    *fiber.App
+	db           *gorm.DB
+	events       []*EvaEvent
+	mu           sync.Mutex
+	run          *simulationRun         // Current simulation run, nil when stopped, guarded by mu
+	stopping     *simulationRun         // Run being drained by a stop, guarded by mu
+	simPaused    bool                   // Scheduled fires keep their timing but skip sends
+	stopReason   StopReason             // Why the last simulation run stopped
+	lastRun      *RunSummary            // Summary of the last stopped run, guarded by mu
+	scheduled    *scheduledStart        // Armed simulation start, guarded by mu
+	timeScale    atomic.Uint64          // math.Float64bits of the running simulation's time scale, 0 outside of a run
+	pulses       map[uint]*pendingPulse // Pending falls of pulsed events by DB ID, guarded by pulseMu
+	pulseMu      sync.Mutex
+	triggerJobs  map[int]*TriggerJob // Pending one-shot triggers by job ID, guarded by jobMu
+	nextJobID    int
+	jobMu        sync.Mutex
+	appCtx       context.Context // Cancelled on shutdown
+	appCancel    context.CancelFunc
+	scenarioRuns playbacks      // Playing scenarios by ID
+	replays      playbacks      // Replaying recordings by ID
+	repeats      playbacks      // Repeated triggers by event DB ID
+	playbackWg   sync.WaitGroup // Scenario runs, replays and repeated triggers
+	recorder     *recorder      // Active recording, guarded by recordMu
+	recordMu     sync.Mutex
+	quietHours   []QuietWindow         // Global quiet hours, guarded by mu
+	loadTest     *loadTest             // Running or last load test, guarded by mu
+	chaos        *chaosRun             // Running or last chaos run, guarded by mu
+	rateCap      rateCap               // Global send rate cap, has its own lock
+	runSends     sendCounts            // Sends per event of the current run, has its own lock
+	delayed      map[*delayedSend]bool // Sends queued by the rate cap, guarded by delayMu
+	delayMu      sync.Mutex
+	settingsMu   sync.Mutex // Serializes settings updates
+
+	scenarioProgress map[uint]ScenarioProgress // Where scenario runs are or stopped by scenario ID, guarded by mu
+
+	sendEvent func(eventID int, build func() (*axevent.AXEvent, error)) error // Sends a platform event, replaced in tests
 }
 
 // NewEvaApplication creates a new instance of EvaApplication.
 func NewEvaApplication() *EvaApplication {
-	return &EvaApplication{
+	appCtx, appCancel := context.WithCancel(context.Background())
+	eva := &EvaApplication{
 		webserver: fiber.New(),
 		acapp:     *acapapp.NewAcapApplication(),
+		appCtx:    appCtx,
+		appCancel: appCancel,
 	}
+	eva.sendEvent = eva.acapp.SendPlatformEvent
+	return eva
 }
 
 func (eva *EvaApplication) InitDB() error {
@@ -45,7 +83,7 @@ func (eva *EvaApplication) InitDB() error {
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
-	if err := db.AutoMigrate(&EvaEvent{}); err != nil {
+	if err := db.AutoMigrate(&EvaEvent{}, &FieldTemplate{}, &Scenario{}, &Recording{}, &Settings{}, &SimulationRunRecord{}); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 	eva.db = db
@@ -61,13 +99,33 @@ func (eva *EvaApplication) Start() {
 	}
 
 	eva.SeedDemoEvents()
+	eva.SeedFieldTemplates()
+	eva.closeAbandonedRuns()
+
+	if settings, err := eva.loadSettings(); err != nil {
+		eva.acapp.Syslog.Critf("Failed to load settings: %v", err)
+	} else {
+		eva.quietHours = settings.QuietHours
+		eva.rateCap.configure(settings.MaxEventsPerSecond, settings.RateCapPolicy)
+	}
 
 	if err := eva.LoadAndRegisterAllEvents(); err != nil {
 		eva.acapp.Syslog.Critf("Failed to register events on startup: %v", err)
 	}
+	eva.resumeRun()
+	eva.startAutoRun()
 
 	eva.acapp.OnCloseCleaners = append(eva.acapp.OnCloseCleaners, func() {
-		eva.StopSimulation()
+		eva.appCancel()
+		eva.playbackWg.Wait()
+		eva.mu.Lock()
+		eva.cancelScheduledStart()
+		eva.mu.Unlock()
+		eva.StopSimulation(StopShutdown, shutdownDrainTimeout)
+		eva.cancelDelayedSends()
+		eva.cancelTriggerJobs(0)
+		eva.cancelAllPulses()
+		eva.sendLowStates()
 		if err := eva.UnregisterAllEvents(); err != nil {
 			eva.acapp.Syslog.Critf("Failed to unregister events on shutdown: %v", err)
 		}
@@ -75,198 +133,1435 @@ func (eva *EvaApplication) Start() {
 		eva.acapp.Syslog.Info("Shutting down Eva - Event Virtualizer for ACAP")
 	})
 
-	eva.webserver.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"*"},
-	}))
-	eva.RegisterRoutes()
-	eva.acapp.RunInBackground()
-	eva.acapp.Syslog.Critf("Webserver error: %v", eva.webserver.Listen(":8746"))
-}
-
-func jsonError(c fiber.Ctx, status int, err error) error {
-	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
-}
+	eva.webserver.Use(cors.New(cors.Config{
+		AllowOrigins: []string{"*"},
+	}))
+	eva.RegisterRoutes()
+	eva.acapp.RunInBackground()
+	eva.acapp.Syslog.Critf("Webserver error: %v", eva.webserver.Listen(":8746"))
+}
+
+func jsonError(c fiber.Ctx, status int, err error) error {
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
+
+// validationError responds with 400 for a failed Validate, listing the problem of each field where available.
+func validationError(c fiber.Ctx, err error) error {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  err.Error(),
+			"fields": validationErr.Errors,
+		})
+	}
+	return jsonError(c, fiber.StatusBadRequest, err)
+}
+
+func (eva *EvaApplication) findEventByID(c fiber.Ctx) (*EvaEvent, error) {
+	var event EvaEvent
+	if err := eva.db.First(&event, c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "event not found"})
+	}
+	return &event, nil
+}
+
+func (eva *EvaApplication) findScenarioByID(c fiber.Ctx) (*Scenario, error) {
+	var scenario Scenario
+	if err := eva.db.First(&scenario, c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "scenario not found"})
+	}
+	return &scenario, nil
+}
+
+func (eva *EvaApplication) findRecordingByID(c fiber.Ctx) (*Recording, error) {
+	var recording Recording
+	if err := eva.db.First(&recording, c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "recording not found"})
+	}
+	return &recording, nil
+}
+
+func (eva *EvaApplication) findFieldTemplateByID(c fiber.Ctx, param string) (*FieldTemplate, error) {
+	var template FieldTemplate
+	if err := eva.db.First(&template, c.Params(param)).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "field template not found"})
+	}
+	return &template, nil
+}
+
+func (eva *EvaApplication) RegisterRoutes() {
+	// List events, filtered, paged and ordered per the query parameters
+	eva.webserver.Get("/events", func(c fiber.Ctx) error {
+		query, err := parseEventListQuery(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		var total int64
+		if err := query.filter(eva.db.Model(&EvaEvent{})).Count(&total).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		events := []EvaEvent{}
+		if err := query.apply(eva.db).Find(&events).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		if err := eva.flagFireConditions(events); err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		c.Set("X-Total-Count", strconv.FormatInt(total, 10))
+		return c.JSON(events)
+	})
+
+	// Download all events, or those of ?ids=1,2,3, as a portable JSON file
+	eva.webserver.Get("/events/export", func(c fiber.Ctx) error {
+		ids, err := parseIDList("ids", c.Query("ids"))
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		export, err := eva.exportEvents(ids)
+		if err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		c.Attachment(fmt.Sprintf("eva-events-%s.json", export.ExportedAt.Format("20060102-150405")))
+		return c.JSON(export)
+	})
+
+	// Create events from an export, as body or multipart file, per ?strategy= for taken names
+	eva.webserver.Post("/events/import", func(c fiber.Ctx) error {
+		strategy, err := parseImportStrategy(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		export, err := readImport(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		result, err := eva.importEvents(export, strategy)
+		if errors.Is(err, errImportRunning) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(result)
+	})
+
+	// Get single event
+	eva.webserver.Get("/events/:id", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		eva.mu.Lock()
+		if registered := eva.findRegisteredEvent(event.ID); registered != nil {
+			event.Counters = registered.CounterValues()
+			event.CounterResets = registered.CounterResetTimes()
+			event.SuppressedSends = registered.SuppressedCount()
+		}
+		eva.mu.Unlock()
+		event.DefaultedFields = event.defaultedFields()
+		events := []EvaEvent{*event}
+		if err := eva.flagFireConditions(events); err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(events[0])
+	})
+
+	// Reset counter fields of an event back to their start value
+	eva.webserver.Post("/events/:id/counters/reset", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		eva.mu.Lock()
+		registered := eva.findRegisteredEvent(event.ID)
+		if registered == nil {
+			eva.mu.Unlock()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event not registered with platform"})
+		}
+		registered.ResetCounters()
+		eva.mu.Unlock()
+		return c.JSON(fiber.Map{"status": "counters reset", "event": event.Name})
+	})
+
+	// Recently generated values of a single field
+	eva.webserver.Get("/events/:id/fields/:key/history", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		field := event.fieldByKey(c.Params("key"))
+		if field == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "field not found"})
+		}
+		key := field.SanitizedKey()
+		history := []HistoryEntry{}
+		eva.mu.Lock()
+		if registered := eva.findRegisteredEvent(event.ID); registered != nil {
+			history = registered.FieldHistory(key)
+		}
+		eva.mu.Unlock()
+		return c.JSON(fiber.Map{"event": event.Name, "field": key, "history": history})
+	})
+
+	// Create event
+	eva.webserver.Post("/events", func(c fiber.Ctx) error {
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot create events while simulation is running"})
+		}
+
+		var newEvent EvaEvent
+		if err := c.Bind().Body(&newEvent); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if err := newEvent.Validate(); err != nil {
+			return validationError(c, err)
+		}
+		if newEvent.Enabled == nil {
+			newEvent.Enabled = boolPtr(true)
+		}
+		if err := eva.db.Create(&newEvent).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		newEvent.Warnings = newEvent.defaultWarnings()
+
+		// Registering and joining happen under one lock, so a concurrent stop either sees the
+		// new job in the scheduler it tears down or the create sees the simulation stopped.
+		eva.mu.Lock()
+		eva.events = append(eva.events, &newEvent)
+		if err := eva.registerEvent(&newEvent); err != nil {
+			eva.mu.Unlock()
+			eva.acapp.Syslog.Critf("Failed to register new event %s: %v", newEvent.Name, err)
+			newEvent.RegistrationError = err.Error()
+			return c.Status(fiber.StatusCreated).JSON(newEvent)
+		}
+		joined := eva.run != nil && eva.startEventRun(&newEvent)
+		newEvent.JoinedRun = &joined
+		eva.mu.Unlock()
+
+		return c.Status(fiber.StatusCreated).JSON(newEvent)
+	})
+
+	// Create several events at once, all or nothing unless ?continue_on_error=true
+	eva.webserver.Post("/events/bulk", func(c fiber.Ctx) error {
+		// Like single creates, the events join a running simulation unless ?strict=true.
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot create events while simulation is running"})
+		}
+
+		var events []EvaEvent
+		if err := c.Bind().Body(&events); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if len(events) > maxBulkEvents {
+			return jsonError(c, fiber.StatusBadRequest, errTooManyBulkEvents)
+		}
+		result, err := eva.createBulk(events, fiber.Query[bool](c, "continue_on_error"))
+		if err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		if result.Created == 0 && result.Failed > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(result)
+		}
+		return c.Status(fiber.StatusCreated).JSON(result)
+	})
+
+	// Copy an event under a new name that does not clash on the platform
+	eva.webserver.Post("/events/:id/clone", func(c fiber.Ctx) error {
+		// Like single creates, the copy joins a running simulation unless ?strict=true.
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot create events while simulation is running"})
+		}
+
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		var req CloneRequest
+		if len(c.Body()) > 0 {
+			if err := c.Bind().Body(&req); err != nil {
+				return jsonError(c, fiber.StatusBadRequest, err)
+			}
+		}
+		if req.Name == "" {
+			req.Name = event.Name + " (copy)"
+		}
+		taken, err := eva.takenEventNames()
+		if err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		clone, err := event.copyEvent()
+		if err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		clone.Name = uniqueEventName(req.Name, taken)
+		if err := clone.Validate(); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Create(&clone).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		clone.Warnings = clone.defaultWarnings()
+
+		eva.mu.Lock()
+		eva.events = append(eva.events, &clone)
+		if err := eva.registerEvent(&clone); err != nil {
+			eva.mu.Unlock()
+			eva.acapp.Syslog.Critf("Failed to register cloned event %s: %v", clone.Name, err)
+			clone.RegistrationError = err.Error()
+			return c.Status(fiber.StatusCreated).JSON(clone)
+		}
+		joined := eva.run != nil && eva.startEventRun(&clone)
+		clone.JoinedRun = &joined
+		eva.mu.Unlock()
+
+		return c.Status(fiber.StatusCreated).JSON(clone)
+	})
+
+	// Update event
+	eva.webserver.Put("/events/:id", func(c fiber.Ctx) error {
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot update events while simulation is running"})
+		}
+
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		previousTypes := event.fieldTypes()
+		if err := c.Bind().Body(event); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		changes, err := event.migrateTypes(previousTypes)
+		if err != nil {
+			return validationError(c, err)
+		}
+		if err := event.Validate(); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Save(event).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+
+		// Changed types change the declaration, so they reach the platform under the same key.
+		eva.applyUpdate(event)
+
+		event.TypeChanges = changes
+		event.Warnings = event.defaultWarnings()
+		return c.JSON(event)
+	})
+
+	// Change only the fields given in the body, see EvaEvent.patched
+	eva.webserver.Patch("/events/:id", func(c fiber.Ctx) error {
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot update events while simulation is running"})
+		}
+
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		patched, err := event.patched(c.Body())
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		changes, err := patched.migrateTypes(event.fieldTypes())
+		if err != nil {
+			return validationError(c, err)
+		}
+		if err := patched.Validate(); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Save(patched).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+
+		eva.applyUpdate(patched)
+
+		patched.TypeChanges = changes
+		patched.Warnings = patched.defaultWarnings()
+		return c.JSON(patched)
+	})
+
+	// Reorder the data fields of an event by name or index
+	eva.webserver.Put("/events/:id/fields/order", func(c fiber.Ctx) error {
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot update events while simulation is running"})
+		}
+
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		var order []interface{}
+		if err := json.Unmarshal(c.Body(), &order); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if err := event.ReorderFields(order); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if err := eva.db.Save(event).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+
+		eva.applyUpdate(event)
+
+		return c.JSON(event)
+	})
+
+	// Append a field from the template library to an event
+	eva.webserver.Post("/events/:id/fields/from-template/:templateId", func(c fiber.Ctx) error {
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot update events while simulation is running"})
+		}
+
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		template, err := eva.findFieldTemplateByID(c, "templateId")
+		if err != nil {
+			return err
+		}
+		event.DataFields = append(event.DataFields, template.Field)
+		if err := event.Validate(); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Save(event).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+
+		eva.applyUpdate(event)
+
+		return c.JSON(event)
+	})
+
+	// Delete event
+	eva.webserver.Delete("/events/:id", func(c fiber.Ctx) error {
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot delete events while simulation is running"})
+		}
+
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+
+		eva.cancelTriggerJobs(event.ID)
+		// Stop firing before the declaration goes away.
+		eva.stopEventRun(event.ID)
+		eva.mu.Lock()
+		registered := eva.findRegisteredEvent(event.ID)
+		if registered != nil {
+			eva.unregisterEvent(registered)
+			eva.removeRegisteredEvent(event.ID)
+		}
+		eva.mu.Unlock()
+
+		if err := eva.db.Delete(&EvaEvent{}, event.ID).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(fiber.Map{"status": "event deleted"})
+	})
+
+	// Delete the events listed in the body, or all events with ?all=true
+	eva.webserver.Delete("/events", func(c fiber.Ctx) error {
+		if eva.strictRunning(c) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot delete events while simulation is running"})
+		}
+
+		var ids []uint
+		if fiber.Query[bool](c, "all") {
+			if err := eva.db.Model(&EvaEvent{}).Pluck("id", &ids).Error; err != nil {
+				return jsonError(c, fiber.StatusInternalServerError, err)
+			}
+		} else {
+			var req BulkDeleteRequest
+			if len(c.Body()) > 0 {
+				if err := c.Bind().Body(&req); err != nil {
+					return jsonError(c, fiber.StatusBadRequest, err)
+				}
+			}
+			if len(req.IDs) == 0 {
+				return jsonError(c, fiber.StatusBadRequest, errors.New("ids must list at least one event, or pass all=true"))
+			}
+			ids = req.IDs
+		}
+		result, err := eva.deleteBulk(ids)
+		if err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(result)
+	})
+
+	// List the categories of the fake data generator
+	eva.webserver.Get("/generators", func(c fiber.Ctx) error {
+		return c.JSON(GeneratorCategories())
+	})
+
+	// List field templates
+	eva.webserver.Get("/field-templates", func(c fiber.Ctx) error {
+		var templates []FieldTemplate
+		if err := eva.db.Find(&templates).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(templates)
+	})
+
+	// Get single field template
+	eva.webserver.Get("/field-templates/:id", func(c fiber.Ctx) error {
+		template, err := eva.findFieldTemplateByID(c, "id")
+		if err != nil {
+			return err
+		}
+		return c.JSON(template)
+	})
+
+	// Create field template
+	eva.webserver.Post("/field-templates", func(c fiber.Ctx) error {
+		var template FieldTemplate
+		if err := c.Bind().Body(&template); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if err := template.Validate(); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Create(&template).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(template)
+	})
+
+	// Update field template
+	eva.webserver.Put("/field-templates/:id", func(c fiber.Ctx) error {
+		template, err := eva.findFieldTemplateByID(c, "id")
+		if err != nil {
+			return err
+		}
+		// Bind into a fresh value so the stored field definition is replaced, not merged.
+		var update FieldTemplate
+		if err := c.Bind().Body(&update); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		update.Model = template.Model
+		if err := update.Validate(); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Save(&update).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(update)
+	})
+
+	// Delete field template
+	eva.webserver.Delete("/field-templates/:id", func(c fiber.Ctx) error {
+		template, err := eva.findFieldTemplateByID(c, "id")
+		if err != nil {
+			return err
+		}
+		if err := eva.db.Delete(&FieldTemplate{}, template.ID).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(fiber.Map{"status": "field template deleted"})
+	})
+
+	// List all scenarios
+	eva.webserver.Get("/scenarios", func(c fiber.Ctx) error {
+		var scenarios []Scenario
+		if err := eva.db.Find(&scenarios).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		for i := range scenarios {
+			eva.fillScenarioStatus(&scenarios[i])
+		}
+		return c.JSON(scenarios)
+	})
+
+	// Get single scenario
+	eva.webserver.Get("/scenarios/:id", func(c fiber.Ctx) error {
+		scenario, err := eva.findScenarioByID(c)
+		if err != nil {
+			return err
+		}
+		eva.fillScenarioStatus(scenario)
+		return c.JSON(scenario)
+	})
+
+	// Create scenario
+	eva.webserver.Post("/scenarios", func(c fiber.Ctx) error {
+		var scenario Scenario
+		if err := c.Bind().Body(&scenario); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if err := scenario.Validate(eva.lookupEvent); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Create(&scenario).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(scenario)
+	})
+
+	// Create a scenario from a CSV or JSON trigger timeline
+	eva.webserver.Post("/scenarios/import", func(c fiber.Ctx) error {
+		rows, err := parseTimeline(c.Body())
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		var events []EvaEvent
+		if err := eva.db.Find(&events).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		steps, problems := timelineSteps(rows, events)
+		if len(problems) > 0 && !fiber.Query[bool](c, "partial") {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "timeline has rows that cannot be imported", "problems": problems})
+		}
+
+		scenario := Scenario{Name: fiber.Query[string](c, "name"), Steps: steps}
+		if scenario.Name == "" {
+			scenario.Name = "Imported " + time.Now().Format("2006-01-02 15:04:05")
+		}
+		if err := scenario.Validate(eva.lookupEvent); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Create(&scenario).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"scenario": scenario, "problems": problems})
+	})
+
+	// Update scenario, a playing run keeps its old steps
+	eva.webserver.Put("/scenarios/:id", func(c fiber.Ctx) error {
+		scenario, err := eva.findScenarioByID(c)
+		if err != nil {
+			return err
+		}
+		// Bind into a fresh value so the stored steps are replaced, not merged.
+		var update Scenario
+		if err := c.Bind().Body(&update); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		update.Model = scenario.Model
+		if err := update.Validate(eva.lookupEvent); err != nil {
+			return validationError(c, err)
+		}
+		if err := eva.db.Save(&update).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		eva.fillScenarioStatus(&update)
+		return c.JSON(update)
+	})
+
+	// Delete scenario, stopping it first
+	eva.webserver.Delete("/scenarios/:id", func(c fiber.Ctx) error {
+		scenario, err := eva.findScenarioByID(c)
+		if err != nil {
+			return err
+		}
+		eva.stopScenario(scenario.ID)
+		if err := eva.db.Delete(&Scenario{}, scenario.ID).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		eva.mu.Lock()
+		delete(eva.scenarioProgress, scenario.ID)
+		eva.mu.Unlock()
+		return c.JSON(fiber.Map{"status": "scenario deleted"})
+	})
+
+	// Play a scenario once or in loops
+	eva.webserver.Post("/scenarios/:id/run", func(c fiber.Ctx) error {
+		scenario, err := eva.findScenarioByID(c)
+		if err != nil {
+			return err
+		}
+		loops, err := parseScenarioLoops(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if !eva.runScenario(scenario, loops) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "scenario already running"})
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "scenario started", "scenario": scenario.Name, "loops": loops.Loops})
+	})
+
+	// Cancel a playing scenario
+	eva.webserver.Post("/scenarios/:id/stop", func(c fiber.Ctx) error {
+		scenario, err := eva.findScenarioByID(c)
+		if err != nil {
+			return err
+		}
+		if !eva.stopScenario(scenario.ID) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "scenario not running"})
+		}
+		return c.JSON(fiber.Map{"status": "scenario stopped", "scenario": scenario.Name, "stopped_at": eva.lastScenarioProgress(scenario.ID)})
+	})
+
+	// Start capturing every event send
+	eva.webserver.Post("/recordings/start", func(c fiber.Ctx) error {
+		startedAt, ok := eva.startRecording()
+		if !ok {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "recording already active"})
+		}
+		return c.JSON(fiber.Map{"status": "recording started", "started_at": startedAt})
+	})
+
+	// Stop capturing and save the recording under an optional name
+	eva.webserver.Post("/recordings/stop", func(c fiber.Ctx) error {
+		name := fiber.Query[string](c, "name")
+		if name == "" && len(c.Body()) > 0 {
+			var body struct {
+				Name string `json:"name"`
+			}
+			if err := c.Bind().Body(&body); err != nil {
+				return jsonError(c, fiber.StatusBadRequest, err)
+			}
+			name = body.Name
+		}
+		recording := eva.stopRecording(name)
+		if recording == nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "no recording active"})
+		}
+		if err := eva.db.Create(recording).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(recording)
+	})
+
+	// List all recordings
+	eva.webserver.Get("/recordings", func(c fiber.Ctx) error {
+		var recordings []Recording
+		if err := eva.db.Find(&recordings).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		for i := range recordings {
+			recordings[i].Replaying = eva.replays.running(recordings[i].ID)
+		}
+		return c.JSON(recordings)
+	})
+
+	// Get single recording
+	eva.webserver.Get("/recordings/:id", func(c fiber.Ctx) error {
+		recording, err := eva.findRecordingByID(c)
+		if err != nil {
+			return err
+		}
+		recording.Replaying = eva.replays.running(recording.ID)
+		return c.JSON(recording)
+	})
+
+	// Delete recording, stopping its replay first
+	eva.webserver.Delete("/recordings/:id", func(c fiber.Ctx) error {
+		recording, err := eva.findRecordingByID(c)
+		if err != nil {
+			return err
+		}
+		eva.replays.stop(recording.ID)
+		if err := eva.db.Delete(&Recording{}, recording.ID).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(fiber.Map{"status": "recording deleted"})
+	})
+
+	// Re-fire a recording with its original payloads and timing
+	eva.webserver.Post("/recordings/:id/replay", func(c fiber.Ctx) error {
+		recording, err := eva.findRecordingByID(c)
+		if err != nil {
+			return err
+		}
+		loop := fiber.Query[bool](c, "loop")
+		if !eva.replayRecording(recording, loop) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "recording already replaying"})
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "replay started", "recording": recording.Name, "loop": loop})
+	})
+
+	// Cancel a running replay
+	eva.webserver.Post("/recordings/:id/replay/stop", func(c fiber.Ctx) error {
+		recording, err := eva.findRecordingByID(c)
+		if err != nil {
+			return err
+		}
+		if !eva.replays.stop(recording.ID) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "recording not replaying"})
+		}
+		return c.JSON(fiber.Map{"status": "replay stopped", "recording": recording.Name})
+	})
+
+	// Start simulation
+	eva.webserver.Post("/simulation/start", func(c fiber.Ctx) error {
+		opts, err := parseRunOptions(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+
+		if err := eva.StartSimulation(opts); err != nil {
+			if errors.Is(err, errSimulationRunning) || errors.Is(err, errSimulationStopping) || errors.Is(err, errLoadTestRunning) {
+				return jsonError(c, fiber.StatusConflict, err)
+			}
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+
+		eva.mu.Lock()
+		eventCount := len(eva.events)
+		response := fiber.Map{"status": "simulation started", "event_count": eventCount, "time_scale": opts.TimeScale}
+		if opts.Duration > 0 {
+			response["stops_at"] = eva.run.stopsAt
+		}
+		if opts.Seed != nil {
+			response["seed"] = *opts.Seed
+		}
+		if opts.Warmup > 0 {
+			response["warmup_ends"] = eva.run.warmupEnds
+		}
+		expected := eva.expectedRate(opts.TimeScale)
+		response["expected_rate"] = expected
+		if limit := eva.rateCap.status().MaxEventsPerSecond; expected > float64(limit) {
+			response["warning"] = fmt.Sprintf("expected rate of %.1f events/s exceeds the global rate cap of %d events/s", expected, limit)
+		}
+		eva.mu.Unlock()
+
+		return c.JSON(response)
+	})
+
+	// Run a load test at a target event rate and answer with its summary once it ended
+	eva.webserver.Post("/simulation/loadtest", func(c fiber.Ctx) error {
+		var req LoadTestRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if err := req.Validate(); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		wait := fiber.Query[bool](c, "wait")
+		if wait {
+			if err := checkWait(req.DurationSeconds); err != nil {
+				return jsonError(c, fiber.StatusBadRequest, err)
+			}
+		}
+		lt, err := eva.startLoadTest(req)
+		if err != nil {
+			if errors.Is(err, errSimulationRunning) || errors.Is(err, errSimulationStopping) || errors.Is(err, errLoadTestRunning) {
+				return jsonError(c, fiber.StatusConflict, err)
+			}
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if !wait {
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "load test started", "result": "/simulation/loadtest/result", "load_test": lt.Result()})
+		}
+		select {
+		case <-lt.done:
+		case <-c.RequestCtx().Done():
+			// The server shuts down and cannot answer anymore.
+			lt.cancel()
+			<-lt.done
+		}
+		return c.JSON(lt.Result())
+	})
+
+	// Cancel the running load test
+	eva.webserver.Delete("/simulation/loadtest", func(c fiber.Ctx) error {
+		if !eva.stopLoadTest() {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "load test not running"})
+		}
+		return c.JSON(fiber.Map{"status": "load test cancelled"})
+	})
+
+	// Summary of the running or last load test
+	eva.webserver.Get("/simulation/loadtest/result", func(c fiber.Ctx) error {
+		eva.mu.Lock()
+		lt := eva.loadTest
+		eva.mu.Unlock()
+		if lt == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no load test has run"})
+		}
+		return c.JSON(lt.Result())
+	})
+
+	// Fire random events at random times in the background, ?wait=true answers once it ended
+	eva.webserver.Post("/simulation/chaos", func(c fiber.Ctx) error {
+		var req ChaosRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if err := req.Validate(); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		wait := fiber.Query[bool](c, "wait")
+		if wait {
+			if err := checkWait(req.DurationSeconds); err != nil {
+				return jsonError(c, fiber.StatusBadRequest, err)
+			}
+		}
+		run, err := eva.startChaos(req)
+		if err != nil {
+			if errors.Is(err, errChaosRunning) {
+				return jsonError(c, fiber.StatusConflict, err)
+			}
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if !wait {
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "chaos run started", "result": "/simulation/chaos/result", "chaos": run.Result()})
+		}
+		select {
+		case <-run.done:
+		case <-c.RequestCtx().Done():
+			// The server shuts down and cannot answer anymore.
+			run.cancel()
+			<-run.done
+		}
+		return c.JSON(run.Result())
+	})
+
+	// Cancel the chaos run
+	eva.webserver.Delete("/simulation/chaos", func(c fiber.Ctx) error {
+		if !eva.stopChaos() {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "chaos run not running"})
+		}
+		return c.JSON(fiber.Map{"status": "chaos run cancelled"})
+	})
+
+	// Summary of the running or last chaos run
+	eva.webserver.Get("/simulation/chaos/result", func(c fiber.Ctx) error {
+		eva.mu.Lock()
+		run := eva.chaos
+		eva.mu.Unlock()
+		if run == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no chaos run has run"})
+		}
+		return c.JSON(run.Result())
+	})
+
+	// Arm a simulation start at a future time
+	eva.webserver.Post("/simulation/schedule", func(c fiber.Ctx) error {
+		startAt, err := parseStartAt(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		opts, err := parseRunOptions(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+
+		eva.mu.Lock()
+		defer eva.mu.Unlock()
+		if eva.run != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation already running"})
+		}
+		if eva.scheduled != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation start already scheduled"})
+		}
+		scheduled := eva.scheduleStart(startAt, opts)
+		return c.JSON(fiber.Map{"status": "simulation scheduled", "scheduled_at": scheduled.startAt})
+	})
+
+	// Cancel the scheduled simulation start
+	eva.webserver.Delete("/simulation/schedule", func(c fiber.Ctx) error {
+		eva.mu.Lock()
+		defer eva.mu.Unlock()
+		if !eva.cancelScheduledStart() {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no simulation start scheduled"})
+		}
+		return c.JSON(fiber.Map{"status": "scheduled start cancelled"})
+	})
+
+	// Stop simulation
+	eva.webserver.Post("/simulation/stop", func(c fiber.Ctx) error {
+		drain, err := parseDrainTimeout(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		eva.mu.Lock()
+		if eva.run == nil {
+			eva.mu.Unlock()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation not running"})
+		}
+		eva.mu.Unlock()
+
+		report := eva.StopSimulation(StopManual, drain)
+
+		eva.mu.Lock()
+		summary := eva.lastRun
+		eva.mu.Unlock()
+		return c.JSON(fiber.Map{"status": "simulation stopped", "drain": report, "summary": summary})
+	})
+
+	// Pause the running simulation without resetting its state
+	eva.webserver.Post("/simulation/pause", func(c fiber.Ctx) error {
+		eva.mu.Lock()
+		defer eva.mu.Unlock()
+		if eva.run == nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation not running"})
+		}
+		if eva.simPaused {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation already paused"})
+		}
+		eva.simPaused = true
+		return c.JSON(fiber.Map{"status": "simulation paused"})
+	})
+
+	// Resume a paused simulation
+	eva.webserver.Post("/simulation/resume", func(c fiber.Ctx) error {
+		eva.mu.Lock()
+		defer eva.mu.Unlock()
+		if eva.run == nil || !eva.simPaused {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation not paused"})
+		}
+		eva.simPaused = false
+		return c.JSON(fiber.Map{"status": "simulation resumed"})
+	})
+
+	// Add a single event to the running simulation
+	eva.webserver.Post("/events/:id/simulation/start", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+
+		eva.mu.Lock()
+		defer eva.mu.Unlock()
+		if eva.run == nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation not running"})
+		}
+		registered := eva.findRegisteredEvent(event.ID)
+		if registered == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event not registered with platform"})
+		}
+		if eva.eventRunning(registered.ID) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "event already running"})
+		}
+		if !registered.IsEnabled() {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "event is disabled"})
+		}
+		if eva.run.autoStart && !registered.autoStarts() {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "only auto-start events run, start the simulation to add other events"})
+		}
+		registered.resetRun()
+		if !eva.startEventRun(registered) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event has no interval, cron or duty cycle to simulate"})
+		}
+		return c.JSON(fiber.Map{"status": "event simulation started", "event": event.Name})
+	})
+
+	// Remove a single event from the running simulation
+	eva.webserver.Post("/events/:id/simulation/stop", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		if !eva.stopEventRun(event.ID) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "event not running"})
+		}
 
-func (eva *EvaApplication) findEventByID(c fiber.Ctx) (*EvaEvent, error) {
-	var event EvaEvent
-	if err := eva.db.First(&event, c.Params("id")).Error; err != nil {
-		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "event not found"})
-	}
-	return &event, nil
-}
+		eva.mu.Lock()
+		if registered := eva.findRegisteredEvent(event.ID); registered != nil {
+			eva.sendLowState(registered)
+		}
+		eva.mu.Unlock()
+		return c.JSON(fiber.Map{"status": "event simulation stopped", "event": event.Name})
+	})
 
-func (eva *EvaApplication) RegisterRoutes() {
-	// List all events
-	eva.webserver.Get("/events", func(c fiber.Ctx) error {
-		var events []EvaEvent
-		if err := eva.db.Find(&events).Error; err != nil {
+	// Enable an event for the simulation and plain triggers, allowed while the simulation runs
+	eva.webserver.Post("/events/:id/enable", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		if err := eva.setEnabled(event, true); err != nil {
 			return jsonError(c, fiber.StatusInternalServerError, err)
 		}
-		return c.JSON(events)
+		return c.JSON(event)
 	})
 
-	// Get single event
-	eva.webserver.Get("/events/:id", func(c fiber.Ctx) error {
+	// Disable an event, it stays registered with the platform
+	eva.webserver.Post("/events/:id/disable", func(c fiber.Ctx) error {
 		event, err := eva.findEventByID(c)
 		if err != nil {
 			return err
 		}
+		if err := eva.setEnabled(event, false); err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
 		return c.JSON(event)
 	})
 
-	// Create event
-	eva.webserver.Post("/events", func(c fiber.Ctx) error {
-		eva.mu.Lock()
-		if eva.simRunning {
-			eva.mu.Unlock()
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot create events while simulation is running"})
+	// Manual trigger a single event by DB id
+	eva.webserver.Post("/events/:id/trigger", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
 		}
-		eva.mu.Unlock()
 
-		var newEvent EvaEvent
-		if err := c.Bind().Body(&newEvent); err != nil {
+		sources, err := event.parseSourceSelection(fiber.Query[string](c, "source"))
+		if err != nil {
 			return jsonError(c, fiber.StatusBadRequest, err)
 		}
-		if err := eva.db.Create(&newEvent).Error; err != nil {
-			return jsonError(c, fiber.StatusInternalServerError, err)
+		delay, err := parseTriggerDelay(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		count, interval, err := parseRepeat(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if count > 1 && delay > 0 {
+			return jsonError(c, fiber.StatusBadRequest, fmt.Errorf("count cannot be combined with a delay"))
 		}
 
 		eva.mu.Lock()
-		eva.events = append(eva.events, &newEvent)
-		if err := eva.registerEvent(&newEvent); err != nil {
+		registered := eva.findRegisteredEvent(event.ID)
+		if registered == nil || registered.EventId == 0 {
 			eva.mu.Unlock()
-			eva.acapp.Syslog.Critf("Failed to register new event %s: %v", newEvent.Name, err)
-			return c.Status(fiber.StatusCreated).JSON(newEvent)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event not registered with platform"})
+		}
+		opts := sendOptions{force: fiber.Query[bool](c, "force"), sources: sources}
+		if !registered.IsEnabled() && !opts.force {
+			eva.mu.Unlock()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "event is disabled, use force=true to trigger it anyway"})
+		}
+		if delay > 0 {
+			job := eva.scheduleTrigger(registered, delay, opts)
+			eva.mu.Unlock()
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "event scheduled", "event": event.Name, "job": job})
+		}
+		if count > 1 {
+			eva.mu.Unlock()
+			job := RepeatJob{EventID: event.ID, Name: event.Name, Count: count, IntervalMs: int(interval / time.Millisecond), StartedAt: time.Now()}
+			if !eva.startRepeat(job, opts) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "event already has a repeated trigger running"})
+			}
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "repeated trigger started", "event": event.Name, "job": job})
+		}
+		if err := eva.triggerEvent(registered, opts); err == nil {
+			registered.recordTrigger()
+			eva.scheduleFollowUps(registered, 0)
+		} else if errors.Is(err, errRateCapped) {
+			eva.mu.Unlock()
+			return jsonError(c, fiber.StatusTooManyRequests, err)
+		} else {
+			eva.mu.Unlock()
+			return jsonError(c, fiber.StatusBadGateway, err)
+		}
+		response := fiber.Map{"status": "event triggered", "event": event.Name}
+		if registered.IsStateful() && registered.stateKey() != "" {
+			response["active"] = registered.Active()
 		}
 		eva.mu.Unlock()
 
-		return c.Status(fiber.StatusCreated).JSON(newEvent)
+		return c.JSON(response)
 	})
 
-	// Update event
-	eva.webserver.Put("/events/:id", func(c fiber.Ctx) error {
+	// Effective interval, probability and band of an event right now
+	eva.webserver.Get("/events/:id/effective", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
 		eva.mu.Lock()
-		if eva.simRunning {
-			eva.mu.Unlock()
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot update events while simulation is running"})
+		defer eva.mu.Unlock()
+		if registered := eva.findRegisteredEvent(event.ID); registered != nil {
+			event = registered
 		}
-		eva.mu.Unlock()
+		return c.JSON(eva.effectiveConfig(event, time.Now()))
+	})
 
+	// Cancel a repeated trigger
+	eva.webserver.Delete("/events/:id/trigger/repeat", func(c fiber.Ctx) error {
 		event, err := eva.findEventByID(c)
 		if err != nil {
 			return err
 		}
-		if err := c.Bind().Body(event); err != nil {
-			return jsonError(c, fiber.StatusBadRequest, err)
+		if !eva.repeats.stop(event.ID) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no repeated trigger running"})
 		}
-		if err := eva.db.Save(event).Error; err != nil {
-			return jsonError(c, fiber.StatusInternalServerError, err)
+		return c.JSON(fiber.Map{"status": "repeated trigger cancelled", "event": event.Name})
+	})
+
+	// Pending one-shot triggers
+	eva.webserver.Get("/trigger-jobs", func(c fiber.Ctx) error {
+		return c.JSON(eva.TriggerJobs())
+	})
+
+	// Cancel a pending one-shot trigger
+	eva.webserver.Delete("/trigger-jobs/:jobId", func(c fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("jobId"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid job id"})
+		}
+		if !eva.cancelTriggerJob(id) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "trigger job not found"})
 		}
+		return c.JSON(fiber.Map{"status": "trigger job cancelled"})
+	})
 
-		eva.mu.Lock()
-		registered := eva.findRegisteredEvent(event.ID)
-		if registered != nil {
-			eva.unregisterEvent(registered)
-			*registered = *event
-			eva.registerEvent(registered)
+	// ONVIF topic of an event, derived from its current definition
+	eva.webserver.Get("/events/:id/topic", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
 		}
-		eva.mu.Unlock()
+		event.SetupPlatformEvent(eva)
+		return c.JSON(event.topicInfo(eva.acapp.Manifest.ACAPPackageConf.Setup.AppName))
+	})
 
-		return c.JSON(event)
+	// Fire an event once and wait for it to come back through an own subscription
+	eva.webserver.Post("/events/:id/verify", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		timeout, err := parseVerifyTimeout(c)
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+
+		result, err := eva.verifyEvent(event.ID, timeout)
+		if errors.Is(err, errVerifyNotRegistered) {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if errors.Is(err, errVerifyTimeout) {
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+				"error":          fmt.Sprintf("%v within %s", err, timeout),
+				"event":          result.Event,
+				"topic":          result.Topic,
+				"declaration_id": result.declarationID,
+				"stateless":      result.stateless,
+			})
+		}
+		if err != nil {
+			return jsonError(c, fiber.StatusBadGateway, err)
+		}
+		return c.JSON(result)
 	})
 
-	// Delete event
-	eva.webserver.Delete("/events/:id", func(c fiber.Ctx) error {
+	// Last sent state of a stateful event
+	eva.webserver.Get("/events/:id/state", func(c fiber.Ctx) error {
+		event, err := eva.findEventByID(c)
+		if err != nil {
+			return err
+		}
+		if !event.IsStateful() || event.stateKey() == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event is not stateful or has no bool state field"})
+		}
+
 		eva.mu.Lock()
-		if eva.simRunning {
-			eva.mu.Unlock()
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot delete events while simulation is running"})
+		defer eva.mu.Unlock()
+		registered := eva.findRegisteredEvent(event.ID)
+		if registered == nil || registered.EventId == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event not registered with platform"})
 		}
-		eva.mu.Unlock()
+		active, payload, sentAt := registered.LastState()
+		response := fiber.Map{"event": event.Name, "state_field": registered.stateKey(), "active": active, "payload": payload, "sent_at": nil}
+		if !sentAt.IsZero() {
+			response["sent_at"] = sentAt
+		}
+		return c.JSON(response)
+	})
 
+	// Send a state of a stateful event and keep it until the next state is sent
+	eva.webserver.Post("/events/:id/state", func(c fiber.Ctx) error {
 		event, err := eva.findEventByID(c)
 		if err != nil {
 			return err
 		}
+		if !event.IsStateful() || event.stateKey() == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event is not stateful or has no bool state field"})
+		}
+		sources, err := event.parseSourceSelection(fiber.Query[string](c, "source"))
+		if err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		var body struct {
+			Active *bool `json:"active"`
+		}
+		if err := c.Bind().Body(&body); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if body.Active == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "active is required"})
+		}
 
 		eva.mu.Lock()
+		defer eva.mu.Unlock()
 		registered := eva.findRegisteredEvent(event.ID)
-		if registered != nil {
-			eva.unregisterEvent(registered)
-			eva.removeRegisteredEvent(event.ID)
+		if registered == nil || registered.EventId == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event not registered with platform"})
 		}
-		eva.mu.Unlock()
-
-		if err := eva.db.Delete(&EvaEvent{}, event.ID).Error; err != nil {
+		// An explicit state replaces a pending pulse fall.
+		eva.cancelPulse(registered.ID)
+		if err := eva.sendState(registered, *body.Active, sendOptions{force: fiber.Query[bool](c, "force"), sources: sources}); err != nil {
 			return jsonError(c, fiber.StatusInternalServerError, err)
 		}
-		return c.JSON(fiber.Map{"status": "event deleted"})
+		active, payload, sentAt := registered.LastState()
+		return c.JSON(fiber.Map{"event": event.Name, "state_field": registered.stateKey(), "active": active, "payload": payload, "sent_at": sentAt})
 	})
 
-	// Start simulation
-	eva.webserver.Post("/simulation/start", func(c fiber.Ctx) error {
+	// Global quiet hours
+	eva.webserver.Get("/settings/quiet-hours", func(c fiber.Ctx) error {
 		eva.mu.Lock()
-		if eva.simRunning {
-			eva.mu.Unlock()
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation already running"})
-		}
-		if len(eva.events) == 0 {
-			eva.mu.Unlock()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no events configured"})
+		defer eva.mu.Unlock()
+		windows := eva.quietHours
+		if windows == nil {
+			windows = []QuietWindow{}
 		}
-		eva.mu.Unlock()
-
-		eva.ctx, eva.cancel = context.WithCancel(context.Background())
-		eva.StartEventSimulation()
+		return c.JSON(windows)
+	})
 
+	// Replace the global quiet hours, applied to the next simulated send
+	eva.webserver.Put("/settings/quiet-hours", func(c fiber.Ctx) error {
+		var windows []QuietWindow
+		if err := c.Bind().Body(&windows); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if errs := validateQuietHours(windows); len(errs) > 0 {
+			return validationError(c, &ValidationError{Errors: errs})
+		}
+		if _, err := eva.updateSettings(func(s *Settings) { s.QuietHours = windows }); err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
 		eva.mu.Lock()
-		eva.simRunning = true
-		eventCount := len(eva.events)
+		eva.quietHours = windows
 		eva.mu.Unlock()
+		eva.acapp.Syslog.Infof("Global quiet hours set to %d window(s)", len(windows))
+		return c.JSON(windows)
+	})
 
-		return c.JSON(fiber.Map{"status": "simulation started", "event_count": eventCount})
+	// Global rate cap
+	eva.webserver.Get("/settings/rate-cap", func(c fiber.Ctx) error {
+		return c.JSON(eva.rateCap.status())
 	})
 
-	// Stop simulation
-	eva.webserver.Post("/simulation/stop", func(c fiber.Ctx) error {
-		eva.mu.Lock()
-		if !eva.simRunning {
-			eva.mu.Unlock()
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "simulation not running"})
+	// Change the global rate cap, applied to the next send
+	eva.webserver.Put("/settings/rate-cap", func(c fiber.Ctx) error {
+		var req RateCapSettings
+		if err := c.Bind().Body(&req); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
 		}
-		eva.mu.Unlock()
+		if errs := req.Validate(); len(errs) > 0 {
+			return validationError(c, &ValidationError{Errors: errs})
+		}
+		if _, err := eva.updateSettings(func(s *Settings) {
+			s.MaxEventsPerSecond = req.MaxEventsPerSecond
+			s.RateCapPolicy = req.Policy
+		}); err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		eva.rateCap.configure(req.MaxEventsPerSecond, req.Policy)
+		eva.acapp.Syslog.Infof("Global rate cap set to %d events/s, policy %s", req.MaxEventsPerSecond, req.Policy)
+		return c.JSON(eva.rateCap.status())
+	})
 
-		eva.StopSimulation()
+	// Whether a simulation running at shutdown resumes on the next start
+	eva.webserver.Get("/settings/auto-resume", func(c fiber.Ctx) error {
+		settings, err := eva.loadSettings()
+		if err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		return c.JSON(AutoResumeSettings{AutoResume: settings.autoResume()})
+	})
 
-		return c.JSON(fiber.Map{"status": "simulation stopped"})
+	// Turn auto-resume on or off
+	eva.webserver.Put("/settings/auto-resume", func(c fiber.Ctx) error {
+		var req AutoResumeSettings
+		if err := c.Bind().Body(&req); err != nil {
+			return jsonError(c, fiber.StatusBadRequest, err)
+		}
+		if _, err := eva.updateSettings(func(s *Settings) { s.AutoResume = boolPtr(req.AutoResume) }); err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		eva.acapp.Syslog.Infof("Simulation auto-resume set to %t", req.AutoResume)
+		return c.JSON(req)
 	})
 
-	// Manual trigger a single event by DB id
-	eva.webserver.Post("/events/:id/trigger", func(c fiber.Ctx) error {
-		event, err := eva.findEventByID(c)
+	// Recorded simulation runs, newest first, without their per-event counts
+	eva.webserver.Get("/simulation/runs", func(c fiber.Ctx) error {
+		page, err := parsePage(c, defaultRunPageLimit)
 		if err != nil {
-			return err
+			return jsonError(c, fiber.StatusBadRequest, err)
 		}
-
-		eva.mu.Lock()
-		registered := eva.findRegisteredEvent(event.ID)
-		if registered == nil || registered.EventId == 0 {
-			eva.mu.Unlock()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event not registered with platform"})
+		var total int64
+		if err := eva.db.Model(&SimulationRunRecord{}).Count(&total).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
 		}
-		eva.acapp.SendPlatformEvent(registered.EventId, func() (*axevent.AXEvent, error) {
-			return registered.PlatformEvent.NewEvent(registered.BuildKeyValueMap())
-		})
-		eva.mu.Unlock()
+		runs := []SimulationRunRecord{}
+		if err := page.apply(eva.db.Omit("events").Order("id DESC")).Find(&runs).Error; err != nil {
+			return jsonError(c, fiber.StatusInternalServerError, err)
+		}
+		c.Set("X-Total-Count", strconv.FormatInt(total, 10))
+		return c.JSON(runs)
+	})
 
-		return c.JSON(fiber.Map{"status": "event triggered", "event": event.Name})
+	// Single recorded simulation run with its per-event counts
+	eva.webserver.Get("/simulation/runs/:id", func(c fiber.Ctx) error {
+		var record SimulationRunRecord
+		if err := eva.db.First(&record, c.Params("id")).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "simulation run not found"})
+		}
+		return c.JSON(record)
 	})
 
 	// Simulation status
 	eva.webserver.Get("/simulation/status", func(c fiber.Ctx) error {
 		eva.mu.Lock()
 		defer eva.mu.Unlock()
-		return c.JSON(fiber.Map{"running": eva.simRunning, "event_count": len(eva.events)})
+		phases := []DutyCycleStatus{}
+		suppressed := 0
+		quiet := 0
+		dropped := 0
+		unmet := 0
+		cron := []CronStatus{}
+		runs := []EventRunStatus{}
+		for _, ev := range eva.events {
+			run := ev.RunStatus()
+			run.Running = eva.run != nil && eva.eventRunning(ev.ID)
+			if eva.run != nil {
+				run.NextFire, _ = eva.run.sched.nextFire(ev.ID)
+			}
+			runs = append(runs, run)
+			quiet += run.Quiet
+			dropped += run.Dropped
+			unmet += run.Unmet
+			if status, ok := ev.DutyCycleStatus(); ok {
+				phases = append(phases, status)
+			}
+			suppressed += ev.SuppressedCount()
+			if status, ok := ev.CronStatus(); ok {
+				cron = append(cron, status)
+			}
+		}
+		status := fiber.Map{"running": eva.run != nil, "stopping": eva.stopping != nil, "paused": eva.simPaused, "event_count": len(eva.events), "pending_pulses": eva.PendingPulses(), "duty_cycles": phases, "suppressed_sends": suppressed, "quiet_skipped": quiet, "probability_skipped": dropped, "condition_skipped": unmet, "quiet_now": inQuietHours(eva.quietHours, time.Now()), "cron": cron, "events": runs}
+		status["rate_cap"] = eva.rateCap.status()
+		if eva.lastRun != nil {
+			status["last_run"] = eva.lastRun
+		}
+		if eva.stopReason != "" {
+			status["stop_reason"] = eva.stopReason
+		}
+		if eva.run != nil {
+			if !eva.run.stopsAt.IsZero() {
+				status["stops_at"] = eva.run.stopsAt
+			}
+			status["time_scale"] = eva.TimeScale()
+			status["ramp_factor"] = eva.run.ramp.factor(time.Now())
+			if eva.run.seed != nil {
+				status["seed"] = *eva.run.seed
+			}
+			status["started_at"] = eva.run.startedAt
+			if eva.run.recordID != 0 {
+				status["run_id"] = eva.run.recordID
+			}
+			if remaining := eva.run.warmupRemaining(time.Now()); remaining > 0 {
+				status["warmup_remaining_seconds"] = remaining.Seconds()
+			}
+			if eva.run.autoStart {
+				status["auto_started"] = true
+			}
+			if eva.run.resumed {
+				status["resumed"] = true
+			}
+		}
+		if eva.scheduled != nil {
+			status["scheduled_at"] = eva.scheduled.startAt
+		}
+		return c.JSON(status)
 	})
 
 	// Serve frontend (must be last)
@@ -292,6 +1587,13 @@ func (eva *EvaApplication) UnregisterAllEvents() error {
 	eva.mu.Lock()
 	defer eva.mu.Unlock()
 	for _, event := range eva.events {
+		// Leave no condition stuck active on the VMS. Events the stop of the simulation already
+		// sent inactive are not sent again.
+		if event.EventId != 0 && event.IsStateful() && event.stateKey() != "" && event.SendInactiveOnShutdown != nil && *event.SendInactiveOnShutdown && event.Active() {
+			if err := eva.sendState(event, false, sendOptions{force: true, uncapped: true}); err != nil {
+				eva.acapp.Syslog.Critf("Failed to send inactive state of %s: %v", event.Name, err)
+			}
+		}
 		if err := eva.unregisterEvent(event); err != nil {
 			return err
 		}
@@ -302,17 +1604,29 @@ func (eva *EvaApplication) UnregisterAllEvents() error {
 // registerEvent registers a single event with the platform. Caller must hold eva.mu.
 func (eva *EvaApplication) registerEvent(event *EvaEvent) error {
 	event.SetupPlatformEvent(eva)
-	regId, err := eva.acapp.AddCameraPlatformEvent(&event.PlatformEvent)
+	var regId int
+	var err error
+	if event.ownDeclaration() {
+		regId, err = eva.declareTopicEvent(event)
+	} else {
+		regId, err = eva.acapp.AddCameraPlatformEvent(&event.PlatformEvent)
+	}
 	if err != nil {
 		return fmt.Errorf("error registering event %s: %s", event.Name, err.Error())
 	}
 	event.EventId = regId
 	eva.acapp.Syslog.Infof("Registered event: %s (id=%d)", event.Name, regId)
+	if event.IsStateful() && event.stateKey() != "" && event.SendInitialState != nil && *event.SendInitialState {
+		if err := eva.sendState(event, event.initialActive(), sendOptions{force: true}); err != nil {
+			eva.acapp.Syslog.Critf("Failed to send initial state of %s: %v", event.Name, err)
+		}
+	}
 	return nil
 }
 
 // unregisterEvent unregisters a single event from the platform. Caller must hold eva.mu.
 func (eva *EvaApplication) unregisterEvent(event *EvaEvent) error {
+	eva.cancelPulse(event.ID)
 	if event.EventId != 0 {
 		if err := eva.acapp.EventHandler.Undeclare(event.EventId); err != nil {
 			return fmt.Errorf("error unregistering event %s: %s", event.Name, err.Error())
@@ -323,6 +1637,21 @@ func (eva *EvaApplication) unregisterEvent(event *EvaEvent) error {
 	return nil
 }
 
+// reregisterEvent replaces the registered copy of event with the new definition and declares it again.
+// A rejected declaration is logged and recorded in event.RegistrationError. Caller must hold eva.mu.
+func (eva *EvaApplication) reregisterEvent(event *EvaEvent) {
+	registered := eva.findRegisteredEvent(event.ID)
+	if registered == nil {
+		return
+	}
+	eva.unregisterEvent(registered)
+	*registered = *event
+	if err := eva.registerEvent(registered); err != nil {
+		eva.acapp.Syslog.Critf("Failed to register event %s: %v", event.Name, err)
+		event.RegistrationError = err.Error()
+	}
+}
+
 // findRegisteredEvent finds an event in the in-memory list by DB ID. Caller must hold eva.mu.
 func (eva *EvaApplication) findRegisteredEvent(dbID uint) *EvaEvent {
 	for _, ev := range eva.events {
@@ -363,62 +1692,92 @@ func (eva *EvaApplication) LoadAndRegisterAllEvents() error {
 	return nil
 }
 
-func (eva *EvaApplication) StartEventSimulation() {
+var (
+	errSimulationRunning  = errors.New("simulation already running")
+	errSimulationStopping = errors.New("simulation is stopping, try again once the stop completed")
+	errNoEvents           = errors.New("no events configured")
+)
+
+// StartSimulation starts a simulation run of all events with the given run settings.
+func (eva *EvaApplication) StartSimulation(opts RunOptions) error {
 	eva.mu.Lock()
 	defer eva.mu.Unlock()
-	for _, event := range eva.events {
-		if event.UseInterval == nil || !*event.UseInterval {
-			continue
-		}
-		useRandom := event.UseRandomInterval != nil && *event.UseRandomInterval && event.IntervalMinSeconds > 0 && event.IntervalMaxSeconds > event.IntervalMinSeconds
-		if !useRandom && event.IntervalSeconds <= 0 {
-			continue
-		}
-		eva.wg.Add(1)
-		if useRandom {
-			go func(ev *EvaEvent) {
-				defer eva.wg.Done()
-				for {
-					delay := RandomIntInRange(ev.IntervalMinSeconds, ev.IntervalMaxSeconds)
-					select {
-					case <-eva.ctx.Done():
-						return
-					case <-time.After(time.Duration(delay) * time.Second):
-						eva.acapp.SendPlatformEvent(ev.EventId, func() (*axevent.AXEvent, error) {
-							return ev.PlatformEvent.NewEvent(ev.BuildKeyValueMap())
-						})
-					}
-				}
-			}(event)
-		} else {
-			go func(ev *EvaEvent) {
-				defer eva.wg.Done()
-				ticker := time.NewTicker(time.Duration(ev.IntervalSeconds) * time.Second)
-				defer ticker.Stop()
-				for {
-					select {
-					case <-eva.ctx.Done():
-						return
-					case <-ticker.C:
-						eva.acapp.SendPlatformEvent(ev.EventId, func() (*axevent.AXEvent, error) {
-							return ev.PlatformEvent.NewEvent(ev.BuildKeyValueMap())
-						})
-					}
-				}
-			}(event)
+	if eva.stopping != nil {
+		return errSimulationStopping
+	}
+	if eva.run != nil {
+		if !eva.run.autoStart {
+			return errSimulationRunning
 		}
+		return eva.absorbAutoRun(opts)
+	}
+	if eva.loadTestRunning() {
+		return errLoadTestRunning
 	}
+	if len(eva.events) == 0 {
+		return errNoEvents
+	}
+
+	eva.timeScale.Store(math.Float64bits(opts.TimeScale))
+	eva.rateCap.resetCounts()
+	eva.run = newSimulationRun(opts)
+	eva.StartEventSimulation()
+	eva.openRunRecord(eva.run, opts.TimeScale)
+	eva.persistRun(runState(eva.run, opts.TimeScale))
+	if opts.Duration > 0 {
+		// Not a goroutine of the run: it stops the run and must not be waited for by the stop.
+		run := eva.run
+		go eva.autoStop(run, opts.Duration)
+	}
+	return nil
+}
+
+// StartEventSimulation schedules all events and starts the scheduler goroutine of the run.
+// Caller must hold eva.mu.
+func (eva *EvaApplication) StartEventSimulation() {
+	for _, event := range eva.events {
+		event.ResetState()
+		eva.startEventRun(event)
+	}
+	eva.run.goRun(eva.run.sched.run)
+}
+
+// StopSimulation stops the running simulation, letting pending sends finish for up to drain,
+// and waits for its goroutines. It does nothing when no simulation is running, so it is safe to
+// call repeatedly and before the first start.
+func (eva *EvaApplication) StopSimulation(reason StopReason, drain time.Duration) DrainReport {
+	return eva.stopRun(nil, reason, drain)
 }
 
-func (eva *EvaApplication) StopSimulation() {
+// stopRun stops run, or the current run when run is nil. A run that already stopped is left alone.
+func (eva *EvaApplication) stopRun(run *simulationRun, reason StopReason, drain time.Duration) DrainReport {
 	eva.mu.Lock()
-	if !eva.simRunning {
+	if eva.run == nil || (run != nil && eva.run != run) {
 		eva.mu.Unlock()
-		return
+		return DrainReport{}
 	}
-	eva.simRunning = false
+	run = eva.run
+	eva.run = nil
+	// Until the drain and the low states are done, no new run may start: the stop resets the
+	// time scale and sends the events low.
+	eva.stopping = run
+	eva.simPaused = false
+	eva.stopReason = reason
 	eva.mu.Unlock()
+	if reason != StopShutdown {
+		eva.persistRun(nil)
+	}
 
-	eva.cancel()
-	eva.wg.Wait()
+	report := eva.drainRun(run, drain)
+	eva.timeScale.Store(0)
+	eva.sendLowStates()
+	summary := eva.closeRunRecord(run, reason)
+	eva.mu.Lock()
+	eva.lastRun = &summary
+	eva.stopping = nil
+	eva.mu.Unlock()
+	if report.Completed > 0 || report.Abandoned > 0 {
+		eva.acapp.Syslog.Infof("Simulation drained: %d sends completed, %d abandoned", report.Completed, report.Abandoned)
+	}
+	return report
 }
@@ -1,7 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Cacsjep/goxis/pkg/acapapp"
 	"github.com/Cacsjep/goxis/pkg/axevent"
@@ -16,66 +25,1071 @@ const (
 	IntType    ValueType = "int"
 	FloatType  ValueType = "float"
 	BoolType   ValueType = "bool"
+	// TimestampType is filled with the wall-clock time at which the event fires.
+	TimestampType ValueType = "timestamp"
+	// EnumType is a string restricted to EnumValues.
+	EnumType ValueType = "enum"
+	// GeoType expands into <key>_lat and <key>_lon doubles inside the Lat/Lon bounding box.
+	GeoType ValueType = "geo"
+	// BBoxType expands into <key>_left, <key>_top, <key>_right and <key>_bottom doubles in [0,1].
+	BBoxType ValueType = "bbox"
+	// ElementType carries a raw XML fragment, optionally templated with other field values.
+	ElementType ValueType = "element"
+)
+
+// Timestamp formats understood by TimestampFormat. Any other non-empty value is used as a Go time layout.
+const (
+	TimestampRFC3339 = "rfc3339"
+	TimestampEpoch   = "epoch"
+	TimestampEpochMs = "epoch_ms"
+)
+
+// Distribution selects how random numeric values are drawn.
+type Distribution string
+
+const (
+	UniformDistribution Distribution = "uniform"
+	NormalDistribution  Distribution = "normal"
+	// PoissonDistribution draws counts with mean Lambda, capped at IntRandEnd when it is set.
+	PoissonDistribution Distribution = "poisson"
+	// ExponentialDistribution draws durations with the given Rate, capped at FloatRandEnd when it is set.
+	ExponentialDistribution Distribution = "exponential"
+)
+
+// SelectionMode selects how values are picked from RandomStrings.
+type SelectionMode string
+
+const (
+	RandomSelection     SelectionMode = "random"
+	SequentialSelection SelectionMode = "sequential"
+)
+
+// FieldMode selects a generator that replaces the static/random value of a field.
+type FieldMode string
+
+const (
+	// CounterMode emits CounterStart, then increments by CounterStep on every payload.
+	CounterMode FieldMode = "counter"
+	// RandomWalkMode drifts from the previous value by at most WalkMaxDelta per payload, clamped to the random range.
+	RandomWalkMode FieldMode = "random_walk"
+	// WaveformMode follows WaveShape over PeriodSeconds between the random range bounds.
+	WaveformMode FieldMode = "waveform"
+	// GeneratorMode builds a random string from Pattern, see ParsePattern.
+	GeneratorMode FieldMode = "generator"
+	// PlateMode generates license plates from PlatePattern, see ExpandPlatePattern.
+	PlateMode FieldMode = "plate"
+	// FakeMode picks realistic values from the built-in Generator category, see generators.go.
+	FakeMode FieldMode = "fake"
+	// MACMode generates random unicast MAC addresses.
+	MACMode FieldMode = "mac"
+	// IPv4Mode generates random host addresses, inside CIDR when it is set.
+	IPv4Mode FieldMode = "ipv4"
+	// JSONBlobMode serializes the generated SubFields into a JSON object string, see jsonblob.go.
+	JSONBlobMode FieldMode = "json_blob"
+	// DerivedMode computes the value from DerivedSources with DerivedOp, see derived.go.
+	DerivedMode FieldMode = "derived"
+)
+
+// EntryRole decides how a field's key is declared on the platform event.
+type EntryRole string
+
+const (
+	// DataRole keys carry the state or value the event is about. This is the default.
+	DataRole EntryRole = "data"
+	// SourceRole keys identify the instance of the event, e.g. a channel, and are selectable
+	// as a filter in the camera's action rules.
+	SourceRole EntryRole = "source"
+)
+
+// Wave shapes understood by WaveformMode.
+const (
+	SineWave     = "sine"
+	SawtoothWave = "sawtooth"
 )
 
 type DataFields struct {
-	Name           string      `json:"name"`
-	Value          interface{} `json:"value"`
-	ValueType      ValueType   `json:"value_type"`
-	UseRandom      bool        `json:"use_random"`
-	IntRandStart   int         `json:"int_rand_start"`
-	IntRandEnd     int         `json:"int_rand_end"`
-	FloatRandStart float64     `json:"float_rand_start"`
-	FloatRandEnd   float64     `json:"float_rand_end"`
-	RandomStrings  []string    `json:"random_strings"`
+	Name                string          `json:"name"`
+	Key                 string          `json:"key"`
+	Value               interface{}     `json:"value"`
+	ValueType           ValueType       `json:"value_type"`
+	UseRandom           bool            `json:"use_random"`
+	IntRandStart        int             `json:"int_rand_start"`
+	IntRandEnd          int             `json:"int_rand_end"`
+	IntRandStep         int             `json:"int_rand_step"`
+	ExcludeValues       []interface{}   `json:"exclude_values"`
+	TimeWindows         []TimeWindow    `json:"time_windows"`
+	FloatRandStart      float64         `json:"float_rand_start"`
+	FloatRandEnd        float64         `json:"float_rand_end"`
+	RandomStrings       []string        `json:"random_strings"`
+	RandomStringWeights []float64       `json:"random_string_weights"`
+	SelectionMode       SelectionMode   `json:"selection_mode"`
+	Distribution        Distribution    `json:"distribution"`
+	Mean                float64         `json:"mean"`
+	StdDev              float64         `json:"std_dev"`
+	Lambda              float64         `json:"lambda"`
+	Rate                float64         `json:"rate"`
+	Mode                FieldMode       `json:"mode"`
+	CounterStart        int             `json:"counter_start"`
+	CounterStep         int             `json:"counter_step"`
+	CounterDailyReset   bool            `json:"counter_daily_reset"`
+	CounterResetTime    string          `json:"counter_reset_time"`
+	TimestampFormat     string          `json:"timestamp_format"`
+	WalkMaxDelta        float64         `json:"walk_max_delta"`
+	WaveShape           string          `json:"wave_shape"`
+	PeriodSeconds       float64         `json:"period_seconds"`
+	Expression          string          `json:"expression"`
+	EnumValues          []string        `json:"enum_values"`
+	RandomSeed          *int64          `json:"random_seed"`
+	LatMin              float64         `json:"lat_min"`
+	LatMax              float64         `json:"lat_max"`
+	LonMin              float64         `json:"lon_min"`
+	LonMax              float64         `json:"lon_max"`
+	BoxMinSize          float64         `json:"box_min_size"`
+	BoxMaxSize          float64         `json:"box_max_size"`
+	Precision           *int            `json:"precision"`
+	TrueProbability     *float64        `json:"true_probability"`
+	Pattern             string          `json:"pattern"`
+	PlatePattern        string          `json:"plate_pattern"`
+	PlateRepeatChance   float64         `json:"plate_repeat_chance"`
+	Generator           string          `json:"generator"`
+	CIDR                string          `json:"cidr"`
+	SubFields           []DataFields    `json:"sub_fields"`
+	DefaultValue        interface{}     `json:"default_value"`
+	EntryRole           EntryRole       `json:"entry_role"`
+	Condition           *FieldCondition `json:"condition"`
+	DerivedOp           DerivedOp       `json:"derived_op"`
+	DerivedSources      []string        `json:"derived_sources"`
+	DerivedConstant     *float64        `json:"derived_constant"`
+}
+
+// FieldError describes one problem with a data field. Field is empty for event-level problems.
+type FieldError struct {
+	Field   string      `json:"field"`
+	Message string      `json:"message"`
+	Value   interface{} `json:"value,omitempty"`
+	Allowed []string    `json:"allowed,omitempty"`
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("field %s: %s", e.Field, e.Message)
+}
+
+// ValidationError collects every problem found while validating an event.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
 }
 
+// SanitizedKey is the wire key of the field: Key when set, otherwise the sanitized Name.
 func (d *DataFields) SanitizedKey() string {
+	if d.Key != "" {
+		return d.Key
+	}
 	return sanitizeEventName(d.Name)
 }
 
-// TypedValue casts the raw JSON value to the correct Go type expected by the AX event system.
+// validKey matches the explicit keys accepted by the event system.
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// keys returns the platform keys the field is sent under.
+func (d *DataFields) keys() []string {
+	parts := d.compoundParts()
+	if parts == nil {
+		return []string{d.SanitizedKey()}
+	}
+	keys := make([]string, len(parts))
+	for i, part := range parts {
+		keys[i] = d.SanitizedKey() + "_" + part
+	}
+	return keys
+}
+
+// AXValueType maps the field's ValueType to the type declared on the platform.
+func (d *DataFields) AXValueType() axevent.AXEventValueType {
+	switch d.ValueType {
+	case IntType:
+		return axevent.AXValueTypeInt
+	case FloatType:
+		return axevent.AXValueTypeDouble
+	case BoolType:
+		return axevent.AXValueTypeBool
+	case TimestampType:
+		switch d.TimestampFormat {
+		case TimestampEpoch:
+			return axevent.AXValueTypeInt
+		case TimestampEpochMs:
+			// Milliseconds overflow the 32-bit int of the event system, doubles hold them exactly.
+			return axevent.AXValueTypeDouble
+		}
+		return axevent.AXValueTypeString
+	case ElementType:
+		// goxis can only marshal int, double, bool and string values, so the XML is sent as its string form.
+		return axevent.AXValueTypeString
+	default:
+		return axevent.AXValueTypeString
+	}
+}
+
+// timestampValue formats t according to TimestampFormat, matching the type returned by AXValueType.
+func (d *DataFields) timestampValue(t time.Time) interface{} {
+	switch d.TimestampFormat {
+	case "", TimestampRFC3339:
+		return t.Format(time.RFC3339)
+	case TimestampEpoch:
+		return int(t.Unix())
+	case TimestampEpochMs:
+		return float64(t.UnixMilli())
+	default:
+		return t.Format(d.TimestampFormat)
+	}
+}
+
+// randomStrings returns the candidates for random or sequential string selection.
+// Enum fields without explicit RandomStrings draw from their allowed values.
+func (d *DataFields) randomStrings() []string {
+	if len(d.RandomStrings) == 0 && d.ValueType == EnumType {
+		return d.EnumValues
+	}
+	return d.RandomStrings
+}
+
+// dependencies returns the sanitized keys of the fields this field is computed from or conditioned on.
+func (d *DataFields) dependencies() []string {
+	refs, _ := d.expressionRefs()
+	elementRefs, _ := d.elementRefs()
+	refs = append(refs, elementRefs...)
+	refs = append(refs, d.derivedRefs()...)
+	if d.Condition != nil {
+		refs = append(refs, d.Condition.ref())
+	}
+	return refs
+}
+
+// Validate checks the field configuration for inconsistencies and returns every problem found.
+func (d *DataFields) Validate() []*FieldError {
+	var errs []*FieldError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Field: d.Name, Message: fmt.Sprintf(format, args...)})
+	}
+	if strings.TrimSpace(d.Name) == "" {
+		fail("data field name must not be empty")
+	}
+	if d.Key != "" && !validKey.MatchString(d.Key) {
+		fail("key %q must start with a letter or underscore and contain only letters, digits and underscores", d.Key)
+	}
+	if !d.valueCoercible(d.Value) {
+		fail("value %v is not a valid %s", d.Value, d.ValueType)
+	}
+	if !d.valueCoercible(d.DefaultValue) {
+		fail("default_value %v is not a valid %s", d.DefaultValue, d.ValueType)
+	}
+	if d.staticValue() == nil && d.needsStaticValue() {
+		fail("value is required unless default_value, use_random, mode or expression is set")
+	}
+	if _, err := d.expressionRefs(); err != nil {
+		fail("invalid expression: %v", err)
+	}
+	if err := d.validateCompound(); err != "" {
+		fail("%s", err)
+	}
+	if d.ValueType == TimestampType && (d.UseRandom || d.Mode != "") {
+		fail("timestamp fields do not support random or generator options")
+	}
+	if d.ValueType == ElementType {
+		if d.UseRandom || d.Mode != "" {
+			fail("element fields do not support random or generator options")
+		}
+		if err := d.validateElement(); err != nil {
+			fail("value is not well-formed XML: %v", err)
+		} else if _, err := d.elementRefs(); err != nil {
+			fail("invalid element template: %v", err)
+		}
+	}
+	if d.UseRandom || d.Mode == RandomWalkMode || d.Mode == WaveformMode {
+		switch d.ValueType {
+		case IntType:
+			if d.IntRandStart > d.IntRandEnd {
+				fail("int_rand_start must not exceed int_rand_end")
+			} else if d.IntRandStep > 1 {
+				if first, last := stepMultiples(d.IntRandStart, d.IntRandEnd, d.IntRandStep); first > last {
+					fail("no multiple of int_rand_step %d between int_rand_start and int_rand_end", d.IntRandStep)
+				}
+			}
+		case FloatType:
+			if d.FloatRandStart > d.FloatRandEnd {
+				fail("float_rand_start must not exceed float_rand_end")
+			}
+		case StringType:
+			if d.UseRandom && len(d.RandomStrings) == 0 {
+				fail("random string fields require random_strings")
+			}
+		}
+	}
+	numericMode := d.Mode == CounterMode || d.Mode == WaveformMode || d.Mode == DerivedMode || (d.Mode == RandomWalkMode && d.compoundParts() == nil)
+	if numericMode && d.ValueType != IntType && d.ValueType != FloatType {
+		fail("%s mode requires an int or float field", d.Mode)
+	}
+	if d.Precision != nil {
+		if d.ValueType != FloatType {
+			fail("precision is only supported on float fields")
+		} else if *d.Precision < 0 || *d.Precision > 15 {
+			fail("precision must be between 0 and 15")
+		}
+	}
+	if d.Condition != nil {
+		if err := d.Condition.validate(); err != nil {
+			fail("%v", err)
+		}
+	}
+	switch d.EntryRole {
+	case "", DataRole:
+	case SourceRole:
+		if d.compoundParts() != nil {
+			fail("%s fields cannot be source keys", d.ValueType)
+		}
+	default:
+		fail("entry_role must be %q or %q", DataRole, SourceRole)
+	}
+	if d.Mode == FakeMode {
+		if d.ValueType != StringType {
+			fail("%s mode requires a string field", d.Mode)
+		}
+		if _, ok := generatorCategories[d.Generator]; !ok {
+			fail("unknown generator %q, see GET /generators", d.Generator)
+		}
+	}
+	if d.Mode == MACMode || d.Mode == IPv4Mode {
+		if d.ValueType != StringType {
+			fail("%s mode requires a string field", d.Mode)
+		}
+	}
+	if d.CIDR != "" {
+		if d.Mode != IPv4Mode {
+			fail("cidr requires the %s mode", IPv4Mode)
+		} else if _, err := parseIPv4CIDR(d.CIDR); err != nil {
+			fail("invalid cidr: %v", err)
+		}
+	}
+	if d.Mode == JSONBlobMode || len(d.SubFields) > 0 {
+		errs = append(errs, d.validateBlob()...)
+	}
+	if d.Mode == PlateMode {
+		if d.ValueType != StringType {
+			fail("%s mode requires a string field", d.Mode)
+		}
+		if strings.TrimSpace(d.PlatePattern) == "" {
+			fail("plate_pattern must not be empty")
+		}
+	}
+	if d.PlateRepeatChance < 0 || d.PlateRepeatChance > 1 {
+		fail("plate_repeat_chance must be between 0 and 1")
+	}
+	if d.Mode == DerivedMode {
+		if err := d.validateDerived(); err != nil {
+			fail("%v", err)
+		}
+	}
+	if d.Mode == GeneratorMode {
+		if d.ValueType != StringType {
+			fail("%s mode requires a string field", d.Mode)
+		}
+		if _, err := ParsePattern(d.Pattern); err != nil {
+			fail("invalid pattern: %v", err)
+		}
+	}
+	if p := d.TrueProbability; p != nil {
+		if d.ValueType != BoolType {
+			fail("true_probability is only supported on bool fields")
+		} else if *p < 0 || *p > 1 {
+			fail("true_probability must be between 0 and 1")
+		}
+	}
+	for _, problem := range d.validateTimeWindows() {
+		fail("%s", problem)
+	}
+	if err := d.validateExclusions(); err != nil {
+		fail("%v", err)
+	}
+	switch d.Distribution {
+	case "", UniformDistribution, NormalDistribution:
+	case PoissonDistribution:
+		if d.ValueType != IntType {
+			fail("%s distribution requires an int field", d.Distribution)
+		}
+		if d.Lambda <= 0 {
+			fail("lambda must be greater than 0")
+		}
+	case ExponentialDistribution:
+		if d.ValueType != FloatType {
+			fail("%s distribution requires a float field", d.Distribution)
+		}
+		if d.Rate <= 0 {
+			fail("rate must be greater than 0")
+		}
+	default:
+		fail("unknown distribution %q", d.Distribution)
+	}
+	if d.IntRandStep < 0 {
+		fail("int_rand_step must not be negative")
+	}
+	if d.CounterDailyReset && d.Mode != CounterMode {
+		fail("counter_daily_reset requires the %s mode", CounterMode)
+	}
+	if d.CounterResetTime != "" {
+		if _, err := parseClock(d.CounterResetTime); err != nil {
+			fail("counter_reset_time: %v", err)
+		}
+	}
+	if d.WalkMaxDelta < 0 {
+		fail("walk_max_delta must not be negative")
+	}
+	if d.Mode == WaveformMode {
+		if d.WaveShape != SineWave && d.WaveShape != SawtoothWave {
+			fail("wave_shape must be %q or %q", SineWave, SawtoothWave)
+		}
+		if d.PeriodSeconds <= 0 {
+			fail("period_seconds must be greater than 0")
+		}
+	}
+	if d.ValueType == EnumType {
+		if len(d.EnumValues) == 0 {
+			fail("enum fields require enum_values")
+		} else {
+			if d.staticValue() != nil && !slices.Contains(d.EnumValues, fmt.Sprintf("%v", d.TypedValue())) {
+				errs = append(errs, &FieldError{Field: d.Name, Message: fmt.Sprintf("value %v is not an allowed value", d.staticValue()), Value: d.staticValue(), Allowed: d.EnumValues})
+			}
+			for _, s := range d.RandomStrings {
+				if !slices.Contains(d.EnumValues, s) {
+					errs = append(errs, &FieldError{Field: d.Name, Message: fmt.Sprintf("random string %s is not an allowed value", s), Value: s, Allowed: d.EnumValues})
+				}
+			}
+		}
+	}
+	if len(d.RandomStringWeights) > 0 {
+		if candidates := d.randomStrings(); len(d.RandomStringWeights) != len(candidates) {
+			fail("random_string_weights has %d entries but there are %d strings to pick from", len(d.RandomStringWeights), len(candidates))
+		}
+		if slices.ContainsFunc(d.RandomStringWeights, func(w float64) bool { return w < 0 }) {
+			fail("random_string_weights must not be negative")
+		}
+	}
+	return errs
+}
+
+// valueCoercible reports whether v can be used as the field's ValueType. A missing value is
+// accepted here; types without a static value accept anything.
+func (d *DataFields) valueCoercible(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case float64:
+		switch d.ValueType {
+		case IntType:
+			return v == math.Trunc(v)
+		case FloatType:
+			return true
+		}
+	case int:
+		return d.ValueType == IntType || d.ValueType == FloatType
+	case bool:
+		return d.ValueType == BoolType
+	case string:
+		return d.ValueType == StringType || d.ValueType == EnumType || d.ValueType == ElementType
+	}
+	return d.ValueType == TimestampType || d.compoundParts() != nil
+}
+
+// coerceValue converts v to the field's ValueType where that is lossless, e.g. 5 to "5" or "2.5"
+// to 2.5. Values that already fit are returned unchanged.
+func (d *DataFields) coerceValue(v interface{}) (interface{}, error) {
+	if d.valueCoercible(v) {
+		return v, nil
+	}
+	switch d.ValueType {
+	case IntType, FloatType:
+		var f float64
+		switch x := v.(type) {
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert %q to %s", x, d.ValueType)
+			}
+			f = parsed
+		case bool:
+			return nil, fmt.Errorf("cannot convert %v to %s", x, d.ValueType)
+		case float64:
+			f = x
+		}
+		if d.ValueType == IntType && f != math.Trunc(f) {
+			return nil, fmt.Errorf("cannot convert %v to int without losing its fraction", v)
+		}
+		return f, nil
+	case BoolType:
+		if x, ok := v.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(x)); err == nil {
+				return b, nil
+			}
+		}
+	case StringType, EnumType, ElementType:
+		return fmt.Sprintf("%v", v), nil
+	}
+	return nil, fmt.Errorf("cannot convert %v to %s", v, d.ValueType)
+}
+
+// TypeChange records a field whose ValueType changed on update.
+type TypeChange struct {
+	Field string    `json:"field"`
+	From  ValueType `json:"from"`
+	To    ValueType `json:"to"`
+}
+
+// migrateTypes coerces Value and DefaultValue of every field whose ValueType differs from its
+// previous type (by key) and returns the changes. Unconvertible values are a *ValidationError.
+func (e *EvaEvent) migrateTypes(previous map[string]ValueType) ([]TypeChange, error) {
+	var changes []TypeChange
+	var errs []*FieldError
+	for i := range e.DataFields {
+		field := &e.DataFields[i]
+		from, ok := previous[field.SanitizedKey()]
+		if !ok || from == field.ValueType {
+			continue
+		}
+		changes = append(changes, TypeChange{Field: field.SanitizedKey(), From: from, To: field.ValueType})
+		if field.Value != nil {
+			value, err := field.coerceValue(field.Value)
+			if err != nil {
+				errs = append(errs, &FieldError{Field: field.Name, Message: err.Error(), Value: field.Value})
+			} else {
+				field.Value = value
+			}
+		}
+		if field.DefaultValue != nil {
+			value, err := field.coerceValue(field.DefaultValue)
+			if err != nil {
+				errs = append(errs, &FieldError{Field: field.Name, Message: "default_value: " + err.Error(), Value: field.DefaultValue})
+			} else {
+				field.DefaultValue = value
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return changes, nil
+}
+
+// fieldTypes returns the ValueType of every field by key.
+func (e *EvaEvent) fieldTypes() map[string]ValueType {
+	types := map[string]ValueType{}
+	for i := range e.DataFields {
+		types[e.DataFields[i].SanitizedKey()] = e.DataFields[i].ValueType
+	}
+	return types
+}
+
+// staticValue is Value, or DefaultValue when Value is missing.
+func (d *DataFields) staticValue() interface{} {
+	if d.Value == nil {
+		return d.DefaultValue
+	}
+	return d.Value
+}
+
+// usesDefault reports whether the field's static value comes from DefaultValue.
+func (d *DataFields) usesDefault() bool {
+	return d.Value == nil && d.DefaultValue != nil
+}
+
+// needsStaticValue reports whether the field emits its static value, i.e. neither random,
+// a generator mode nor an expression replaces it.
+func (d *DataFields) needsStaticValue() bool {
+	if d.UseRandom || d.Mode != "" || d.Expression != "" {
+		return false
+	}
+	switch d.ValueType {
+	case StringType, IntType, FloatType, BoolType, EnumType:
+		return true
+	}
+	return false
+}
+
+// TypedValue casts the raw JSON value (or DefaultValue when it is missing) to the correct Go type expected by the AX event system.
 // JSON deserializes all numbers as float64, so we must convert explicitly.
 func (d *DataFields) TypedValue() interface{} {
+	value := d.staticValue()
 	switch d.ValueType {
 	case IntType:
-		if f, ok := d.Value.(float64); ok {
+		if f, ok := value.(float64); ok {
 			return int(f)
 		}
-		if i, ok := d.Value.(int); ok {
+		if i, ok := value.(int); ok {
 			return i
 		}
 		return 0
 	case FloatType:
-		if f, ok := d.Value.(float64); ok {
+		if f, ok := value.(float64); ok {
 			return f
 		}
 		return 0.0
 	case BoolType:
-		if b, ok := d.Value.(bool); ok {
+		if b, ok := value.(bool); ok {
 			return b
 		}
 		return false
+	case TimestampType:
+		return d.timestampValue(time.Now())
 	default:
-		if s, ok := d.Value.(string); ok {
+		if s, ok := value.(string); ok {
 			return s
 		}
-		return fmt.Sprintf("%v", d.Value)
+		if value == nil {
+			return ""
+		}
+		return fmt.Sprintf("%v", value)
 	}
 }
 
 type EvaEvent struct {
 	gorm.Model
-	Name               string                      `json:"name"`
-	UseInterval        *bool                       `json:"use_interval"`
-	IntervalSeconds    int                         `json:"interval_seconds"`
-	UseRandomInterval  *bool                       `json:"use_random_interval"`
-	IntervalMinSeconds int                         `json:"interval_min_seconds"`
-	IntervalMaxSeconds int                         `json:"interval_max_seconds"`
-	DataFields         []DataFields                `gorm:"serializer:json"`
-	Stateless          *bool                       `json:"stateless"`
-	PlatformEvent      acapapp.CameraPlatformEvent `gorm:"-" json:"-"` // Filled at runtime after creation
-	EventId            int                         `gorm:"-" json:"-"` // Filled at runtime after creation
+	Name                   string                      `json:"name"`
+	NiceName               string                      `json:"nice_name"`
+	Description            string                      `json:"description"`
+	Enabled                *bool                       `gorm:"default:true" json:"enabled"`
+	AutoStart              *bool                       `json:"auto_start"`
+	UseInterval            *bool                       `json:"use_interval"`
+	IntervalSeconds        int                         `json:"interval_seconds"`
+	IntervalMs             int                         `json:"interval_ms"`
+	UseRandomInterval      *bool                       `json:"use_random_interval"`
+	IntervalMinSeconds     int                         `json:"interval_min_seconds"`
+	IntervalMaxSeconds     int                         `json:"interval_max_seconds"`
+	JitterPercent          int                         `json:"jitter_percent"`
+	AlignToClock           *bool                       `json:"align_to_clock"`
+	CronSpec               string                      `json:"cron_spec"`
+	MaxTriggers            int                         `json:"max_triggers"`
+	FireProbability        *float64                    `json:"fire_probability"`
+	AdvanceSkippedTicks    *bool                       `json:"advance_skipped_ticks"`
+	DataFields             []DataFields                `gorm:"serializer:json"`
+	Stateless              *bool                       `json:"stateless"`
+	RandomSeed             *int64                      `json:"random_seed"`
+	StateField             string                      `json:"state_field"`
+	ActiveDurationSeconds  int                         `json:"active_duration_seconds"`
+	PulseDurationMs        int                         `json:"pulse_duration_ms"`
+	ActiveSeconds          int                         `json:"active_seconds"`
+	InactiveSeconds        int                         `json:"inactive_seconds"`
+	InitialState           *bool                       `json:"initial_state"`
+	SendInitialState       *bool                       `json:"send_initial_state"`
+	SendInactiveOnShutdown *bool                       `json:"send_inactive_on_shutdown"`
+	SendLowOnStop          *bool                       `json:"send_low_on_stop"`
+	SuppressUnchanged      *bool                       `json:"suppress_unchanged"`
+	Sources                []EventSource               `gorm:"serializer:json" json:"sources"`
+	Topics                 []TopicLevel                `gorm:"serializer:json" json:"topics"`
+	QuietHours             []QuietWindow               `gorm:"serializer:json" json:"quiet_hours"`
+	FollowUps              []FollowUp                  `gorm:"serializer:json" json:"follow_ups"`
+	TimeBands              []TimeBand                  `gorm:"serializer:json" json:"time_bands"`
+	FireCondition          *FireCondition              `gorm:"serializer:json" json:"fire_condition"`
+	PlatformEvent          acapapp.CameraPlatformEvent `gorm:"-" json:"-"`                            // Filled at runtime after creation
+	EventId                int                         `gorm:"-" json:"-"`                            // Filled at runtime after creation
+	Counters               map[string]int              `gorm:"-" json:"counters,omitempty"`           // Filled from the registered event on read
+	CounterResets          map[string]time.Time        `gorm:"-" json:"counter_resets,omitempty"`     // Filled from the registered event on read
+	SuppressedSends        int                         `gorm:"-" json:"suppressed_sends,omitempty"`   // Filled from the registered event on read
+	DefaultedFields        []string                    `gorm:"-" json:"defaulted_fields,omitempty"`   // Filled on read
+	TypeChanges            []TypeChange                `gorm:"-" json:"type_changes,omitempty"`       // Filled by update when field types changed
+	RegistrationError      string                      `gorm:"-" json:"registration_error,omitempty"` // Filled when the platform rejected the declaration
+	Warnings               []string                    `gorm:"-" json:"warnings,omitempty"`           // Filled by create and update
+	JoinedRun              *bool                       `gorm:"-" json:"joined_run,omitempty"`         // Filled by create: whether the event joined the running simulation
+	ConditionError         string                      `gorm:"-" json:"condition_error,omitempty"`    // Filled on read when fire_condition references a deleted event
+	state                  *eventState                 // Filled by SetupPlatformEvent, so every registered event has it
+}
+
+// eventState holds generator state that survives across payloads of a registered event.
+// Simulation goroutines and manual triggers build payloads concurrently, so it has its own lock.
+type eventState struct {
+	mu              sync.Mutex
+	fields          map[string]*fieldState
+	startedAt       time.Time           // Phase origin of waveform fields
+	rng             *rand.Rand          // Seeded from EvaEvent.RandomSeed, nil when unseeded
+	runRng          *rand.Rand          // Seeded from the run seed and the event ID, nil without a run seed
+	active          bool                // Last state sent by a stateful event
+	payload         acapapp.KeyValueMap // Payload of the last state sent
+	sentAt          time.Time           // When the last state was sent
+	phase           DutyPhase           // Current duty cycle phase, empty outside of a duty cycle run
+	phaseEnds       time.Time           // When the current duty cycle phase ends
+	skipped         int                 // State sends suppressed by SuppressUnchanged
+	nextFire        time.Time           // Next scheduled cron fire, zero outside of a cron run
+	fired           int                 // Simulated fires in the current run
+	quiet           int                 // Simulated sends held by quiet hours in the current run
+	dropped         int                 // Simulated ticks skipped by FireProbability in the current run
+	triggers        int                 // Manual triggers since the simulation last started
+	unmet           int                 // Simulated sends skipped by FireCondition in the current run
+	conditionWarned bool                // The FireCondition referencing a deleted event was warned about in this run
+	lastPayload     acapapp.KeyValueMap // Payload of the last send of any kind
+	lastSentAt      time.Time           // When the last payload was sent
+}
+
+// fieldState is the per-field part of eventState, keyed by the sanitized field key.
+type fieldState struct {
+	cursor      int
+	counter     int
+	counterUsed bool
+	counterAt   time.Time // When the counter last restarted at CounterStart
+	walk        float64
+	walkUsed    bool
+	lat, lon    float64
+	box         box
+	plates      []string     // Plates emitted this run, for PlateRepeatChance
+	rng         *rand.Rand   // Seeded from DataFields.RandomSeed, nil when unseeded
+	runRng      *rand.Rand   // Seeded from the run seed and the event ID, nil without a run seed
+	history     valueHistory // Values recently sent, see FieldHistory
+}
+
+// nextCounter advances a counter field and returns the new value. Caller must hold eventState.mu.
+// With CounterDailyReset the counter restarts on the first payload after the daily reset time.
+func (fs *fieldState) nextCounter(field *DataFields, now time.Time) int {
+	if field.CounterDailyReset && fs.counterUsed && dailyBoundary(field.CounterResetTime, now).After(fs.counterAt) {
+		fs.counterUsed = false
+	}
+	if !fs.counterUsed {
+		fs.counter = field.CounterStart
+		fs.counterUsed = true
+		fs.counterAt = now
+		return fs.counter
+	}
+	step := field.CounterStep
+	if step == 0 {
+		step = 1
+	}
+	fs.counter += step
+	return fs.counter
+}
+
+func newEventState() *eventState {
+	return &eventState{fields: map[string]*fieldState{}, startedAt: time.Now()}
+}
+
+// field returns the state for key, creating it on first use. Caller must hold s.mu.
+func (s *eventState) field(key string) *fieldState {
+	fs, ok := s.fields[key]
+	if !ok {
+		fs = &fieldState{}
+		s.fields[key] = fs
+	}
+	return fs
+}
+
+// CounterValues returns the last emitted value of every counter field that has fired.
+func (e *EvaEvent) CounterValues() map[string]int {
+	values := map[string]int{}
+	if e.state == nil {
+		return values
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	for _, field := range e.DataFields {
+		if field.Mode != CounterMode {
+			continue
+		}
+		key := field.SanitizedKey()
+		if fs, ok := e.state.fields[key]; ok && fs.counterUsed {
+			values[key] = fs.counter
+		}
+	}
+	return values
+}
+
+// CounterResetTimes returns when each counter field last restarted at its start value, keyed by field key.
+func (e *EvaEvent) CounterResetTimes() map[string]time.Time {
+	times := map[string]time.Time{}
+	if e.state == nil {
+		return times
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	for _, field := range e.DataFields {
+		if field.Mode != CounterMode {
+			continue
+		}
+		key := field.SanitizedKey()
+		if fs, ok := e.state.fields[key]; ok && fs.counterUsed {
+			times[key] = fs.counterAt
+		}
+	}
+	return times
+}
+
+// defaultedFields returns the names of the fields whose static value comes from DefaultValue.
+func (e *EvaEvent) defaultedFields() []string {
+	var names []string
+	for i := range e.DataFields {
+		if e.DataFields[i].usesDefault() {
+			names = append(names, e.DataFields[i].Name)
+		}
+	}
+	return names
+}
+
+// defaultWarnings lists the event settings that were omitted and fall back to a default.
+func (e *EvaEvent) defaultWarnings() []string {
+	var warnings []string
+	if e.Stateless == nil {
+		warnings = append(warnings, "stateless is not set, the event is declared stateless; set it explicitly")
+	}
+	if e.UseInterval == nil {
+		warnings = append(warnings, "use_interval is not set, the simulation does not fire the event; set it explicitly")
+	}
+	return warnings
+}
+
+// ResetCounters restarts all counter fields at their configured start value.
+func (e *EvaEvent) ResetCounters() {
+	if e.state == nil {
+		return
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	for _, fs := range e.state.fields {
+		fs.counter = 0
+		fs.counterUsed = false
+	}
+}
+
+// maxRememberedPlates bounds the plates kept per field for PlateRepeatChance.
+const maxRememberedPlates = 100
+
+// nextPlate returns a fresh plate, or with PlateRepeatChance one already emitted this run.
+// Caller must hold eventState.mu.
+func (fs *fieldState) nextPlate(r RandSource, field *DataFields) string {
+	if len(fs.plates) > 0 && r.Float64() < field.PlateRepeatChance {
+		return fs.plates[r.Intn(len(fs.plates))]
+	}
+	plate := ExpandPlatePattern(r, field.PlatePattern)
+	if len(fs.plates) == maxRememberedPlates {
+		fs.plates = fs.plates[1:]
+	}
+	fs.plates = append(fs.plates, plate)
+	return plate
+}
+
+// nextWalk moves a random walk field by a random step and returns the new position. The walk
+// starts at the field's static value; ints move in whole steps. Caller must hold eventState.mu.
+func (fs *fieldState) nextWalk(r RandSource, field *DataFields) float64 {
+	min, max := field.FloatRandStart, field.FloatRandEnd
+	if field.ValueType == IntType {
+		min, max = float64(field.IntRandStart), float64(field.IntRandEnd)
+	}
+	if !fs.walkUsed {
+		fs.walkUsed = true
+		switch v := field.TypedValue().(type) {
+		case int:
+			fs.walk = float64(v)
+		case float64:
+			fs.walk = v
+		}
+	} else if field.ValueType == IntType {
+		delta := int(field.WalkMaxDelta)
+		fs.walk += float64(RandomIntInRange(r, -delta, delta))
+	} else {
+		fs.walk += RandomFloatInRange(r, -field.WalkMaxDelta, field.WalkMaxDelta)
+	}
+	fs.walk = clamp(fs.walk, min, max)
+	return fs.walk
+}
+
+// randFor returns the random source for a field: its own seeded generator, the event's
+// seeded generator, the generator derived from the run seed, or the global source. Seeded
+// generators restart with the event state, so every simulation run replays the same sequence.
+// Caller must hold e.state.mu.
+func (e *EvaEvent) randFor(field *DataFields) RandSource {
+	if field.RandomSeed != nil {
+		fs := e.state.field(field.SanitizedKey())
+		if fs.rng == nil {
+			fs.rng = rand.New(rand.NewSource(*field.RandomSeed))
+		}
+		return fs.rng
+	}
+	if e.RandomSeed != nil {
+		if e.state.rng == nil {
+			e.state.rng = rand.New(rand.NewSource(*e.RandomSeed))
+		}
+		return e.state.rng
+	}
+	if e.state.runRng != nil {
+		return e.state.runRng
+	}
+	return GlobalRand
+}
+
+// ResetState discards all generator state, e.g. sequential cursors, of the event.
+func (e *EvaEvent) ResetState() {
+	if e.state == nil {
+		e.state = newEventState()
+	}
+	e.state.mu.Lock()
+	e.state.fields = map[string]*fieldState{}
+	e.state.startedAt = time.Now()
+	e.state.rng = nil
+	e.state.active = e.initialActive()
+	e.state.payload = nil
+	e.state.sentAt = time.Time{}
+	e.state.skipped = 0
+	e.state.fired = 0
+	e.state.quiet = 0
+	e.state.dropped = 0
+	e.state.triggers = 0
+	e.state.unmet = 0
+	e.state.conditionWarned = false
+	e.state.mu.Unlock()
+}
+
+// Validate checks the event and all of its data fields. Field names must be unique once sanitized,
+// since they become the keys of the platform event. All problems are returned as one *ValidationError.
+func (e *EvaEvent) Validate() error {
+	var errs []*FieldError
+	if strings.TrimSpace(e.Name) == "" {
+		errs = append(errs, &FieldError{Message: "event name must not be empty"})
+	}
+	seen := map[string]string{}
+	for i := range e.DataFields {
+		field := &e.DataFields[i]
+		errs = append(errs, field.Validate()...)
+		for _, key := range field.keys() {
+			if other, ok := seen[key]; ok {
+				errs = append(errs, &FieldError{Field: field.Name, Message: fmt.Sprintf("key %s is already used by field %s", key, other)})
+				continue
+			}
+			seen[key] = field.Name
+		}
+	}
+	errs = append(errs, e.validateSchedule()...)
+	errs = append(errs, e.validateState()...)
+	errs = append(errs, e.validateDutyCycle()...)
+	errs = append(errs, e.validateSources()...)
+	errs = append(errs, e.validateTopics()...)
+	errs = append(errs, e.validateQuietHours()...)
+	errs = append(errs, e.validateFireProbability()...)
+	errs = append(errs, e.validateFollowUps()...)
+	errs = append(errs, e.validateTimeBands()...)
+	errs = append(errs, e.validateFireCondition()...)
+	if len(errs) == 0 {
+		if _, err := fieldOrder(e.DataFields); err != nil {
+			var fe *FieldError
+			if !errors.As(err, &fe) {
+				fe = &FieldError{Message: err.Error()}
+			}
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// fieldByKey returns the field whose key is key, also accepting the field name.
+func (e *EvaEvent) fieldByKey(key string) *DataFields {
+	for i := range e.DataFields {
+		if e.DataFields[i].SanitizedKey() == key || e.DataFields[i].SanitizedKey() == sanitizeEventName(key) {
+			return &e.DataFields[i]
+		}
+	}
+	return nil
+}
+
+// ReorderFields rearranges DataFields. Each entry of order is a field name (or sanitized key)
+// or a zero-based index, and together they must name every field exactly once.
+func (e *EvaEvent) ReorderFields(order []interface{}) error {
+	if len(order) != len(e.DataFields) {
+		return fmt.Errorf("order has %d entries but the event has %d fields", len(order), len(e.DataFields))
+	}
+	reordered := make([]DataFields, 0, len(order))
+	used := make([]bool, len(e.DataFields))
+	for _, entry := range order {
+		idx := -1
+		switch v := entry.(type) {
+		case float64:
+			if v == math.Trunc(v) && v >= 0 && int(v) < len(e.DataFields) {
+				idx = int(v)
+			}
+		case string:
+			for i := range e.DataFields {
+				if e.DataFields[i].Name == v || e.DataFields[i].SanitizedKey() == v || e.DataFields[i].SanitizedKey() == sanitizeEventName(v) {
+					idx = i
+					break
+				}
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("unknown field %v", entry)
+		}
+		if used[idx] {
+			return fmt.Errorf("field %v listed more than once", entry)
+		}
+		used[idx] = true
+		reordered = append(reordered, e.DataFields[idx])
+	}
+	e.DataFields = reordered
+	return nil
+}
+
+// fieldOrder returns the indices of fields in an order where every field comes after the fields
+// it depends on, keeping declaration order otherwise. Unknown references and cycles are errors.
+func fieldOrder(fields []DataFields) ([]int, error) {
+	index := map[string]int{}
+	for i := range fields {
+		// References are case-insensitive, explicit keys may be mixed case.
+		index[strings.ToLower(fields[i].SanitizedKey())] = i
+	}
+	deps := make([][]int, len(fields))
+	for i := range fields {
+		for _, ref := range fields[i].dependencies() {
+			j, ok := index[ref]
+			if !ok {
+				return nil, &FieldError{Field: fields[i].Name, Message: fmt.Sprintf("references unknown field %q", ref)}
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	marks := make([]int, len(fields))
+	order := make([]int, 0, len(fields))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch marks[i] {
+		case visiting:
+			return &FieldError{Field: fields[i].Name, Message: "circular reference"}
+		case done:
+			return nil
+		}
+		marks[i] = visiting
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		marks[i] = done
+		order = append(order, i)
+		return nil
+	}
+	for i := range fields {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
 }
 
 func (e *EvaEvent) SetupPlatformEvent(eva *EvaApplication) {
@@ -83,62 +1097,254 @@ func (e *EvaEvent) SetupPlatformEvent(eva *EvaApplication) {
 		Name:      sanitizeEventName(e.Name),
 		NiceName:  utils.StrPtr(e.Name),
 		Entries:   []*acapapp.EventEntry{},
-		Stateless: *e.Stateless,
+		Stateless: !e.IsStateful(),
 	}
 	for _, dataField := range e.DataFields {
-		var valueType axevent.AXEventValueType
-		switch dataField.ValueType {
-		case StringType:
-			valueType = axevent.AXValueTypeString
-		case IntType:
-			valueType = axevent.AXValueTypeInt
-		case FloatType:
-			valueType = axevent.AXValueTypeDouble
-		case BoolType:
-			valueType = axevent.AXValueTypeBool
-		default:
-			valueType = axevent.AXValueTypeString
+		if dataField.compoundParts() != nil {
+			eavt.Entries = append(eavt.Entries, dataField.compoundEntries()...)
+			continue
+		}
+		isSource := dataField.EntryRole == SourceRole
+		isData := !isSource
+		value := dataField.TypedValue()
+		if e.IsStateful() && dataField.SanitizedKey() == e.stateKey() {
+			value = e.initialActive()
 		}
-		isData := true
 		entry := &acapapp.EventEntry{
 			Key:         dataField.SanitizedKey(),
-			Value:       dataField.TypedValue(),
-			ValueType:   valueType,
+			Value:       value,
+			ValueType:   dataField.AXValueType(),
 			KeyNiceName: &dataField.Name,
 			IsData:      &isData,
+			IsSource:    &isSource,
 		}
 		eavt.Entries = append(eavt.Entries, entry)
 	}
+	eavt.Entries = append(eavt.Entries, e.sourceEntries()...)
 	e.PlatformEvent = *eavt
+	e.ResetState()
 }
 
 func (e *EvaEvent) BuildKeyValueMap() acapapp.KeyValueMap {
+	return e.buildKeyValueMap(nil)
+}
+
+// buildKeyValueMap generates a payload in which the keys of overrides take the given values
+// instead of being generated. Source keys missing from overrides get a random source value.
+func (e *EvaEvent) buildKeyValueMap(overrides map[string]interface{}) acapapp.KeyValueMap {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+
+	now := time.Now()
 	kvmap := acapapp.KeyValueMap{}
-	for _, field := range e.DataFields {
+	e.generateFields(e.DataFields, "", now, kvmap, overrides)
+	e.addSources(kvmap, overrides)
+	e.recordHistory(kvmap, now)
+	return kvmap
+}
+
+// generateFields generates fields in dependency order into values, expanding compound fields
+// into one key per part. statePrefix namespaces the generator state of nested fields, overrides
+// replaces the generated value of its keys. Caller must hold e.state.mu.
+func (e *EvaEvent) generateFields(fields []DataFields, statePrefix string, now time.Time, values map[string]interface{}, overrides map[string]interface{}) {
+	band := e.activeBand(now)
+	order, err := fieldOrder(fields)
+	if err != nil {
+		// Validation rejects this on save; fall back to declaration order.
+		order = make([]int, len(fields))
+		for i := range order {
+			order[i] = i
+		}
+	}
+
+	for _, i := range order {
+		field := &fields[i]
+		if field.Condition != nil && !field.Condition.met(values) {
+			continue
+		}
 		key := field.SanitizedKey()
-		kvmap[key] = field.TypedValue()
-		if field.UseRandom {
-			switch field.ValueType {
-			case IntType:
-				kvmap[key] = RandomIntInRange(field.IntRandStart, field.IntRandEnd)
-			case FloatType:
-				kvmap[key] = RandomFloatInRange(field.FloatRandStart, field.FloatRandEnd)
-			case StringType:
-				if len(field.RandomStrings) > 0 {
-					kvmap[key] = RandomStringFromSlice(field.RandomStrings)
-				}
-			case BoolType:
-				kvmap[key] = RandomBool()
+		if value, ok := overrides[key]; ok {
+			values[key] = value
+			continue
+		}
+		value := e.generateValue(band.scale(field.atTime(now)), statePrefix+key, values)
+		if parts, ok := value.(compoundValue); ok {
+			for part, v := range parts {
+				values[key+"_"+part] = v
 			}
+			continue
 		}
+		values[key] = field.round(value)
 	}
-	return kvmap
+}
+
+// round applies Precision to a generated float value. Other values are returned unchanged.
+func (d *DataFields) round(value interface{}) interface{} {
+	if f, ok := value.(float64); ok && d.Precision != nil {
+		return RoundTo(f, *d.Precision)
+	}
+	return value
+}
+
+// generateValue produces the runtime value of a single field. Fields it depends on are
+// already present in values, key identifies the field's generator state. Caller must hold e.state.mu.
+func (e *EvaEvent) generateValue(field *DataFields, key string, values map[string]interface{}) interface{} {
+	if field.Expression != "" {
+		if value, err := field.evalExpression(values); err == nil {
+			return value
+		}
+		return field.TypedValue()
+	}
+	switch field.ValueType {
+	case TimestampType:
+		return field.timestampValue(time.Now())
+	case ElementType:
+		return field.elementValue(values)
+	case GeoType:
+		if !field.UseRandom && field.Mode == "" {
+			return field.compoundStatic()
+		}
+		return e.state.field(key).nextGeo(e.randFor(field), field)
+	case BBoxType:
+		if !field.UseRandom && field.Mode == "" {
+			return field.compoundStatic()
+		}
+		return e.state.field(key).nextBox(e.randFor(field), field)
+	}
+	switch field.Mode {
+	case CounterMode:
+		value := e.state.field(key).nextCounter(field, time.Now())
+		if field.ValueType == FloatType {
+			return float64(value)
+		}
+		return value
+	case RandomWalkMode:
+		value := e.state.field(key).nextWalk(e.randFor(field), field)
+		if field.ValueType == IntType {
+			return int(value)
+		}
+		return value
+	case WaveformMode:
+		elapsed := time.Since(e.state.startedAt).Seconds()
+		if field.ValueType == IntType {
+			return int(math.Round(WaveformValue(field.WaveShape, elapsed, field.PeriodSeconds, float64(field.IntRandStart), float64(field.IntRandEnd))))
+		}
+		return WaveformValue(field.WaveShape, elapsed, field.PeriodSeconds, field.FloatRandStart, field.FloatRandEnd)
+	case DerivedMode:
+		value, err := field.derivedValue(values)
+		if err != nil {
+			return field.TypedValue()
+		}
+		if field.ValueType == IntType {
+			return int(math.Round(value))
+		}
+		return value
+	case PlateMode:
+		return e.state.field(key).nextPlate(e.randFor(field), field)
+	case FakeMode:
+		if category, ok := generatorCategories[field.Generator]; ok {
+			return category.generate(e.randFor(field))
+		}
+		return field.TypedValue()
+	case MACMode:
+		return RandomMAC(e.randFor(field))
+	case IPv4Mode:
+		subnet, err := parseIPv4CIDR(field.CIDR)
+		if err != nil {
+			return field.TypedValue()
+		}
+		return RandomIPv4(e.randFor(field), subnet)
+	case JSONBlobMode:
+		return e.blobValue(field, key)
+	case GeneratorMode:
+		parts, err := ParsePattern(field.Pattern)
+		if err != nil {
+			return field.TypedValue()
+		}
+		return RandomPatternString(e.randFor(field), parts)
+	}
+
+	if !field.UseRandom {
+		return field.TypedValue()
+	}
+	r := e.randFor(field)
+	switch field.ValueType {
+	case IntType, FloatType, BoolType:
+		if len(field.ExcludeValues) > 0 {
+			return field.drawAllowed(r, func() interface{} { return randomScalar(r, field) })
+		}
+		return randomScalar(r, field)
+	case StringType, EnumType:
+		candidates, weights := field.allowedStrings()
+		if len(candidates) == 0 {
+			break
+		}
+		if field.SelectionMode == SequentialSelection {
+			fs := e.state.field(key)
+			value := candidates[fs.cursor%len(candidates)]
+			fs.cursor = (fs.cursor + 1) % len(candidates)
+			return value
+		}
+		return RandomWeightedStringFromSlice(r, candidates, weights)
+	}
+	return field.TypedValue()
+}
+
+// randomScalar draws a random int, float or bool value for the field.
+func randomScalar(r RandSource, field *DataFields) interface{} {
+	switch field.ValueType {
+	case IntType:
+		if field.Distribution == NormalDistribution {
+			value := int(math.Round(RandomNormalInRange(r, field.Mean, field.StdDev, float64(field.IntRandStart), float64(field.IntRandEnd))))
+			if field.IntRandStep > 1 {
+				return NearestStepMultiple(value, field.IntRandStart, field.IntRandEnd, field.IntRandStep)
+			}
+			return value
+		}
+		if field.Distribution == PoissonDistribution {
+			value := RandomPoisson(r, field.Lambda)
+			if field.IntRandEnd > 0 {
+				value = min(value, field.IntRandEnd)
+			}
+			return value
+		}
+		if field.IntRandStep > 1 {
+			return RandomIntStepInRange(r, field.IntRandStart, field.IntRandEnd, field.IntRandStep)
+		}
+		return RandomIntInRange(r, field.IntRandStart, field.IntRandEnd)
+	case FloatType:
+		if field.Distribution == NormalDistribution {
+			return RandomNormalInRange(r, field.Mean, field.StdDev, field.FloatRandStart, field.FloatRandEnd)
+		}
+		if field.Distribution == ExponentialDistribution {
+			value := RandomExponential(r, field.Rate)
+			if field.FloatRandEnd > 0 {
+				value = math.Min(value, field.FloatRandEnd)
+			}
+			return value
+		}
+		return RandomFloatInRange(r, field.FloatRandStart, field.FloatRandEnd)
+	case BoolType:
+		if field.TrueProbability != nil {
+			return RandomBoolWeighted(r, *field.TrueProbability)
+		}
+		return RandomBool(r)
+	}
+	return field.TypedValue()
 }
 
 func boolPtr(b bool) *bool {
 	return &b
 }
 
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
 // SeedDemoEvents inserts demo events into the database if it's empty.
 func (eva *EvaApplication) SeedDemoEvents() {
 	var count int64
@@ -169,6 +1375,7 @@ func (eva *EvaApplication) SeedDemoEvents() {
 			DataFields: []DataFields{
 				{Name: "Crossings In", Value: 0, ValueType: IntType, UseRandom: true, IntRandStart: 0, IntRandEnd: 50},
 				{Name: "Crossings Out", Value: 0, ValueType: IntType, UseRandom: true, IntRandStart: 0, IntRandEnd: 50},
+				{Name: "Net", Value: 0, ValueType: IntType, Mode: DerivedMode, DerivedOp: DerivedDiff, DerivedSources: []string{"Crossings In", "Crossings Out"}},
 				{Name: "Object Type", Value: "Person", ValueType: StringType, UseRandom: true, RandomStrings: []string{"Person", "Vehicle"}},
 			},
 		},
@@ -237,7 +1444,7 @@ func (eva *EvaApplication) SeedDemoEvents() {
 			Stateless:       boolPtr(true),
 			DataFields: []DataFields{
 				{Name: "Occupancy Count", Value: 0, ValueType: IntType, UseRandom: true, IntRandStart: 0, IntRandEnd: 30},
-				{Name: "Occupancy Percent", Value: 0.0, ValueType: FloatType, UseRandom: true, FloatRandStart: 0.0, FloatRandEnd: 100.0},
+				{Name: "Occupancy Percent", Value: 0.0, ValueType: FloatType, Mode: DerivedMode, DerivedOp: DerivedPercent, DerivedSources: []string{"Occupancy Count"}, DerivedConstant: floatPtr(30), Precision: intPtr(1)},
 				{Name: "Scenario", Value: "Zone A", ValueType: StringType, UseRandom: true, RandomStrings: []string{"Zone A", "Zone B", "Entrance", "Exit"}},
 			},
 		},
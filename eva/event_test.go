@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   EvaEvent
+		omitted []string // JSON keys left out of the encoding
+	}{
+		{
+			name:    "minimal",
+			event:   EvaEvent{Name: "Minimal", Enabled: boolPtr(true)},
+			omitted: []string{"counters", "counter_resets", "suppressed_sends", "defaulted_fields", "type_changes", "registration_error", "warnings", "joined_run", "condition_error"},
+		},
+		{
+			name: "description",
+			event: EvaEvent{
+				Name:        "Described",
+				Enabled:     boolPtr(false),
+				Description: "Customer A: loitering at gate 3\nsee ticket 42, 100% \"quoted\"",
+			},
+		},
+		{
+			name: "nested settings",
+			event: EvaEvent{
+				Name:            "Nested",
+				Enabled:         boolPtr(true),
+				UseInterval:     boolPtr(true),
+				IntervalSeconds: 5,
+				FireProbability: floatPtr(0.5),
+				DataFields:      []DataFields{{Name: "Zone", Key: "zone", Value: "north", ValueType: StringType, RandomStrings: []string{"north", "south"}}},
+				Sources:         []EventSource{{Key: "zone", NiceName: "Zone", Values: []string{"north", "south"}}},
+				Topics:          []TopicLevel{{Namespace: "tnsaxis", Name: "Gate"}},
+				QuietHours:      []QuietWindow{{Start: "22:00", End: "06:00"}, {Start: "12:00", End: "13:00", Days: []string{"SAT", "SUN"}}},
+				FollowUps:       []FollowUp{{EventID: 2, MinDelayMs: 100, MaxDelayMs: 500, Probability: floatPtr(0.8)}},
+				TimeBands:       []TimeBand{{Name: "night", Start: "20:00", End: "06:00", IntervalMs: 60000}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys map[string]json.RawMessage
+			if err := json.Unmarshal(data, &keys); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.omitted {
+				if _, ok := keys[key]; ok {
+					t.Errorf("JSON has %q, want it omitted when unset", key)
+				}
+			}
+			var decoded EvaEvent
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, tt.event) {
+				t.Errorf("JSON round trip:\n got %+v\nwant %+v", decoded, tt.event)
+			}
+
+			eva, _ := newTestEva(t)
+			stored := tt.event
+			if err := eva.db.Create(&stored).Error; err != nil {
+				t.Fatal(err)
+			}
+			var loaded EvaEvent
+			if err := eva.db.First(&loaded, stored.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			loaded.Model = gorm.Model{}
+			if !reflect.DeepEqual(loaded, tt.event) {
+				t.Errorf("DB round trip:\n got %+v\nwant %+v", loaded, tt.event)
+			}
+		})
+	}
+}
+
+func TestEventReadOnlyFieldsAreNotStored(t *testing.T) {
+	eva, _ := newTestEva(t)
+	ev := EvaEvent{
+		Name:            "Read only",
+		Enabled:         boolPtr(true),
+		Counters:        map[string]int{"count": 3},
+		CounterResets:   map[string]time.Time{"count": time.Now()},
+		Warnings:        []string{"warned"},
+		ConditionError:  "missing",
+		SuppressedSends: 2,
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"counters", "counter_resets", "warnings", "condition_error", "suppressed_sends"} {
+		if !strings.Contains(string(data), `"`+key+`"`) {
+			t.Errorf("JSON lacks %q although it is set", key)
+		}
+	}
+	if err := eva.db.Create(&ev).Error; err != nil {
+		t.Fatal(err)
+	}
+	var loaded EvaEvent
+	if err := eva.db.First(&loaded, ev.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Counters != nil || loaded.CounterResets != nil || loaded.Warnings != nil || loaded.ConditionError != "" || loaded.SuppressedSends != 0 {
+		t.Errorf("read-only fields were stored: %+v", loaded)
+	}
+}
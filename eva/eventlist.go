@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// eventSortColumns maps the accepted "sort" query values of the event list to their columns.
+var eventSortColumns = map[string]string{
+	"name":             "name",
+	"created_at":       "created_at",
+	"interval_seconds": "interval_seconds",
+}
+
+// eventFilterColumns lists the boolean filters of the event list with their columns and the
+// value an unset column stands for.
+var eventFilterColumns = []struct {
+	param  string
+	column string
+	unset  bool
+}{
+	{"stateless", "stateless", true},
+	{"use_interval", "use_interval", false},
+	{"enabled", "enabled", true},
+}
+
+// EventListQuery is the filter and ordering of an event list request, read from the query
+// parameters. Without a sort the events are listed by ID.
+type EventListQuery struct {
+	Page
+	Sort         string
+	Desc         bool
+	NameContains string
+	Flags        map[string]bool
+}
+
+// parseEventListQuery reads the page and ordering of a GET /events request. The default page
+// holds all events.
+func parseEventListQuery(c fiber.Ctx) (EventListQuery, error) {
+	page, err := parsePage(c, 0)
+	if err != nil {
+		return EventListQuery{}, err
+	}
+	query := EventListQuery{Page: page, Sort: c.Query("sort"), NameContains: c.Query("name_contains"), Flags: map[string]bool{}}
+	for _, filter := range eventFilterColumns {
+		q := c.Query(filter.param)
+		if q == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(q)
+		if err != nil {
+			return EventListQuery{}, fmt.Errorf("invalid %s %q, expected true or false", filter.param, q)
+		}
+		query.Flags[filter.param] = value
+	}
+	if query.Sort != "" {
+		if _, ok := eventSortColumns[query.Sort]; !ok {
+			return EventListQuery{}, fmt.Errorf("invalid sort %q, expected name, created_at or interval_seconds", query.Sort)
+		}
+	}
+	switch order := c.Query("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return EventListQuery{}, fmt.Errorf("invalid order %q, expected asc or desc", order)
+	}
+	return query, nil
+}
+
+// filter restricts db to the events matching the query. An event setting that is not set
+// matches like its default, e.g. an event without stateless counts as stateless. NameContains
+// matches the name or the description.
+func (q EventListQuery) filter(db *gorm.DB) *gorm.DB {
+	for _, filter := range eventFilterColumns {
+		if value, ok := q.Flags[filter.param]; ok {
+			db = db.Where(fmt.Sprintf("COALESCE(%s, ?) = ?", filter.column), filter.unset, value)
+		}
+	}
+	if q.NameContains != "" {
+		pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.NameContains)
+		db = db.Where(`(name LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`, "%"+pattern+"%", "%"+pattern+"%")
+	}
+	return db
+}
+
+// apply filters, orders and pages db to the query. Ties are broken by ID so pages stay stable
+// between calls.
+func (q EventListQuery) apply(db *gorm.DB) *gorm.DB {
+	direction := "ASC"
+	if q.Desc {
+		direction = "DESC"
+	}
+	if column, ok := eventSortColumns[q.Sort]; ok {
+		db = db.Order(column + " " + direction)
+	}
+	return q.Page.apply(q.filter(db).Order("id " + direction))
+}
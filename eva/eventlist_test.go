@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEventListNameContains(t *testing.T) {
+	eva, _ := newTestEva(t)
+	events := []EvaEvent{
+		{Name: "Gate Motion", Description: "Customer A", Enabled: boolPtr(true)},
+		{Name: "Line Crossing", Description: "customer b, 50% of traffic", Enabled: boolPtr(true)},
+		{Name: "Loitering", Description: "door_3", Enabled: boolPtr(false)},
+		{Name: "Tamper", Enabled: boolPtr(true)},
+	}
+	if err := eva.db.Create(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		contains string
+		flags    map[string]bool
+		want     []string
+	}{
+		{contains: "gate", want: []string{"Gate Motion"}},
+		{contains: "customer", want: []string{"Gate Motion", "Line Crossing"}},
+		{contains: "customer", flags: map[string]bool{"enabled": false}, want: nil},
+		{contains: "door_", want: []string{"Loitering"}},
+		{contains: "r_", want: []string{"Loitering"}},
+		{contains: "50%", want: []string{"Line Crossing"}},
+		{contains: "%", want: []string{"Line Crossing"}},
+		{contains: "ing", flags: map[string]bool{"enabled": true}, want: []string{"Line Crossing"}},
+	}
+	for _, tt := range tests {
+		var found []EvaEvent
+		q := EventListQuery{NameContains: tt.contains, Flags: tt.flags}
+		if err := q.apply(eva.db.Model(&EvaEvent{})).Find(&found).Error; err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, ev := range found {
+			names = append(names, ev.Name)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("name_contains=%q %v: got %v, want %v", tt.contains, tt.flags, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+// IsEnabled reports whether the simulation and plain triggers fire the event. Unset means enabled.
+func (e *EvaEvent) IsEnabled() bool {
+	return e.Enabled == nil || *e.Enabled
+}
+
+// simulationJob returns the scheduler job that simulates ev, or nil when the simulation does not fire it.
+func (eva *EvaApplication) simulationJob(run *simulationRun, ev *EvaEvent) *simJob {
+	if !ev.IsEnabled() || ev.UseInterval == nil || !*ev.UseInterval {
+		return nil
+	}
+	switch {
+	case ev.hasDutyCycle():
+		return eva.dutyCycleJob(run, ev)
+	case ev.CronSpec != "":
+		return eva.cronJob(run, ev)
+	case ev.hasInterval():
+		return eva.intervalJob(run, ev)
+	}
+	return nil
+}
+
+// startEventRun schedules ev in the running simulation. It returns false when the simulation
+// does not fire the event. Caller must hold eva.mu.
+func (eva *EvaApplication) startEventRun(ev *EvaEvent) bool {
+	if eva.run == nil || (eva.run.autoStart && !ev.autoStarts()) {
+		return false
+	}
+	ev.seedRun(eva.run.seed)
+	job := eva.simulationJob(eva.run, ev)
+	if job == nil {
+		return false
+	}
+	eva.run.sched.add(job, true)
+	return true
+}
+
+// stopEventRun removes the event with the given DB ID from the running simulation and waits
+// for a fire in progress. It returns false when the event was not running. Caller must not
+// hold eva.mu.
+func (eva *EvaApplication) stopEventRun(dbID uint) bool {
+	eva.mu.Lock()
+	run := eva.run
+	eva.mu.Unlock()
+	if run == nil {
+		return false
+	}
+	return run.sched.remove(dbID)
+}
+
+// eventRunning reports whether the event is scheduled in the running simulation, false once it
+// reached MaxTriggers. Caller must hold eva.mu.
+func (eva *EvaApplication) eventRunning(dbID uint) bool {
+	return eva.run != nil && eva.run.sched.scheduled(dbID)
+}
+
+// setEnabled stores the enabled flag of the event and applies it to a running simulation:
+// disabling removes the event from it and sends its inactive state, enabling starts it.
+func (eva *EvaApplication) setEnabled(event *EvaEvent, enabled bool) error {
+	if err := eva.db.Model(event).Update("enabled", enabled).Error; err != nil {
+		return err
+	}
+	event.Enabled = boolPtr(enabled)
+
+	if !enabled {
+		eva.stopEventRun(event.ID)
+	}
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	registered := eva.findRegisteredEvent(event.ID)
+	if registered == nil {
+		return nil
+	}
+	registered.Enabled = boolPtr(enabled)
+	if !enabled {
+		eva.sendLowState(registered)
+		return nil
+	}
+	if eva.run != nil && !eva.eventRunning(registered.ID) {
+		registered.resetRun()
+		eva.startEventRun(registered)
+	}
+	return nil
+}
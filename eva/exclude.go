@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ExcludeValues removes values from a field's random generation: string candidates are filtered
+// before selection, numbers and bools are re-drawn.
+
+// maxExcludeRetries bounds the re-draws of a random value that hit ExcludeValues.
+const maxExcludeRetries = 100
+
+// maxEnumeratedInts bounds how many ints are listed when re-drawing keeps hitting exclusions.
+const maxEnumeratedInts = 100000
+
+// floatExcludeEpsilon is the tolerance when matching numbers against ExcludeValues.
+const floatExcludeEpsilon = 1e-9
+
+// isExcluded reports whether value matches one of ExcludeValues. Numbers compare numerically,
+// everything else by its string form.
+func (d *DataFields) isExcluded(value interface{}) bool {
+	for _, excluded := range d.ExcludeValues {
+		if valuesMatch(value, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+func valuesMatch(a, b interface{}) bool {
+	if af, ok := numericValue(a); ok {
+		if bf, ok := numericValue(b); ok {
+			return math.Abs(af-bf) <= floatExcludeEpsilon
+		}
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// allowedStrings returns the random string candidates that are not excluded, with their weights.
+func (d *DataFields) allowedStrings() ([]string, []float64) {
+	candidates := d.randomStrings()
+	if len(d.ExcludeValues) == 0 {
+		return candidates, d.RandomStringWeights
+	}
+	var allowed []string
+	var weights []float64
+	for i, candidate := range candidates {
+		if d.isExcluded(candidate) {
+			continue
+		}
+		allowed = append(allowed, candidate)
+		if len(d.RandomStringWeights) == len(candidates) {
+			weights = append(weights, d.RandomStringWeights[i])
+		}
+	}
+	return allowed, weights
+}
+
+// intChoices returns the first and last candidate of the random int range and its step.
+func (d *DataFields) intChoices() (first, last, step int) {
+	if d.IntRandStep > 1 {
+		first, last = stepMultiples(d.IntRandStart, d.IntRandEnd, d.IntRandStep)
+		return first, last, d.IntRandStep
+	}
+	return d.IntRandStart, d.IntRandEnd, 1
+}
+
+// allowedInts lists the ints of the random range that are not excluded, or nil for huge ranges.
+func (d *DataFields) allowedInts() []int {
+	first, last, step := d.intChoices()
+	if (last-first)/step+1 > maxEnumeratedInts {
+		return nil
+	}
+	var allowed []int
+	for v := first; v <= last; v += step {
+		if !d.isExcluded(v) {
+			allowed = append(allowed, v)
+		}
+	}
+	return allowed
+}
+
+// drawAllowed calls draw until it returns a value that is not excluded. When the retries run out,
+// ints are picked from the enumerated allowed values and bools flip.
+func (d *DataFields) drawAllowed(r RandSource, draw func() interface{}) interface{} {
+	value := draw()
+	for i := 0; i < maxExcludeRetries && d.isExcluded(value); i++ {
+		value = draw()
+	}
+	if !d.isExcluded(value) {
+		return value
+	}
+	switch v := value.(type) {
+	case int:
+		if allowed := d.allowedInts(); len(allowed) > 0 {
+			return allowed[r.Intn(len(allowed))]
+		}
+	case bool:
+		return !v
+	}
+	return value
+}
+
+// validateExclusions rejects exclusions that leave nothing to pick from.
+func (d *DataFields) validateExclusions() error {
+	if len(d.ExcludeValues) == 0 || !d.UseRandom {
+		return nil
+	}
+	empty := false
+	switch d.ValueType {
+	case IntType:
+		first, last, step := d.intChoices()
+		if first > last {
+			return nil
+		}
+		excluded := map[int]bool{}
+		for _, value := range d.ExcludeValues {
+			v, ok := numericValue(value)
+			if !ok || v != math.Trunc(v) || int(v) < first || int(v) > last || (int(v)-first)%step != 0 {
+				continue
+			}
+			excluded[int(v)] = true
+		}
+		empty = len(excluded) >= (last-first)/step+1
+	case FloatType:
+		empty = d.FloatRandStart == d.FloatRandEnd && d.isExcluded(d.FloatRandStart)
+	case StringType, EnumType:
+		allowed, _ := d.allowedStrings()
+		empty = len(allowed) == 0 && len(d.randomStrings()) > 0
+	case BoolType:
+		empty = d.isExcluded(true) && d.isExcluded(false)
+	}
+	if empty {
+		return fmt.Errorf("exclude_values leave no value to pick from")
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// eventExportFormat is the version of the event export format, raised on incompatible changes.
+const eventExportFormat = 1
+
+// EventExport is a portable document of events, e.g. to move a demo to another camera. The
+// event IDs are kept so references between the exported events can be restored on import.
+type EventExport struct {
+	FormatVersion int        `json:"format_version"`
+	EvaVersion    string     `json:"eva_version"`
+	ExportedAt    time.Time  `json:"exported_at"`
+	Events        []EvaEvent `json:"events"`
+}
+
+// parseIDList reads a comma-separated list of IDs such as "1,2,3".
+func parseIDList(param, list string) ([]uint, error) {
+	var ids []uint
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 0)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid %s entry %q, expected a comma-separated list of event IDs", param, part)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// evaVersion returns the version of Eva from its manifest.
+func (eva *EvaApplication) evaVersion() string {
+	if eva.acapp.Manifest == nil {
+		return ""
+	}
+	return eva.acapp.Manifest.ACAPPackageConf.Setup.Version
+}
+
+// exportEvents returns the export of the events with the given IDs, of all events when ids is
+// empty.
+func (eva *EvaApplication) exportEvents(ids []uint) (EventExport, error) {
+	export := EventExport{FormatVersion: eventExportFormat, EvaVersion: eva.evaVersion(), ExportedAt: time.Now(), Events: []EvaEvent{}}
+	query := eva.db.Order("id")
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if err := query.Find(&export.Events).Error; err != nil {
+		return export, err
+	}
+	return export, nil
+}
+
+// ImportStrategy tells what an import does with an event whose platform name is taken.
+type ImportStrategy string
+
+const (
+	ImportSkip      ImportStrategy = "skip"      // Keep the existing event, import nothing
+	ImportOverwrite ImportStrategy = "overwrite" // Replace the settings of the existing event
+	ImportRename    ImportStrategy = "rename"    // Import under a numbered name
+)
+
+// parseImportStrategy reads the "strategy" query parameter of an import, skip by default.
+func parseImportStrategy(c fiber.Ctx) (ImportStrategy, error) {
+	strategy := ImportStrategy(c.Query("strategy", string(ImportSkip)))
+	switch strategy {
+	case ImportSkip, ImportOverwrite, ImportRename:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid strategy %q, expected skip, overwrite or rename", strategy)
+}
+
+// readImport reads an export from a multipart "file" upload or the request body and checks its
+// format version.
+func readImport(c fiber.Ctx) (EventExport, error) {
+	var export EventExport
+	body := c.Body()
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return export, fmt.Errorf("multipart upload needs a file field: %w", err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return export, err
+		}
+		defer file.Close()
+		if body, err = io.ReadAll(file); err != nil {
+			return export, err
+		}
+	}
+	if err := json.Unmarshal(body, &export); err != nil {
+		return export, fmt.Errorf("invalid export file: %w", err)
+	}
+	if export.FormatVersion != eventExportFormat {
+		return export, fmt.Errorf("unsupported format_version %d, expected %d", export.FormatVersion, eventExportFormat)
+	}
+	return export, nil
+}
+
+// ImportItem is the outcome for one event of an import.
+type ImportItem struct {
+	Index             int           `json:"index"` // Position in the file
+	Name              string        `json:"name"`
+	RenamedFrom       string        `json:"renamed_from,omitempty"` // Name in the file when renamed
+	ID                uint          `json:"id,omitempty"`           // Created or overwritten event
+	Status            string        `json:"status"`                 // "created", "overwritten", "skipped" or "invalid"
+	Error             string        `json:"error,omitempty"`
+	Fields            []*FieldError `json:"fields,omitempty"`
+	Warnings          []string      `json:"warnings,omitempty"`
+	RegistrationError string        `json:"registration_error,omitempty"`
+}
+
+// ImportResult is the response of an import.
+type ImportResult struct {
+	Created     int          `json:"created"`
+	Overwritten int          `json:"overwritten"`
+	Skipped     int          `json:"skipped"`
+	Failed      int          `json:"failed"`
+	Items       []ImportItem `json:"items"`
+}
+
+// errImportRunning reports an import refused because a simulation runs or stops.
+var errImportRunning = errors.New("cannot import events while simulation is running")
+
+// importEvents imports the events of export. Each event is validated on its own and an invalid
+// one is reported without stopping the others. An event whose platform name is taken, by a
+// stored event or one earlier in the file, is handled by strategy. All changes are stored in
+// one transaction, then the events are registered. Fire conditions are pointed at the imported
+// or kept events; a condition on any other event is removed with a warning. It fails with
+// errImportRunning while a simulation runs or stops.
+func (eva *EvaApplication) importEvents(export EventExport, strategy ImportStrategy) (ImportResult, error) {
+	// Held until the events are registered, so no simulation starts halfway through.
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	if eva.run != nil || eva.stopping != nil {
+		return ImportResult{}, errImportRunning
+	}
+	var stored []EvaEvent
+	if err := eva.db.Find(&stored).Error; err != nil {
+		return ImportResult{}, err
+	}
+	existing := map[string]*EvaEvent{}
+	for i := range stored {
+		existing[sanitizeEventName(stored[i].Name)] = &stored[i]
+	}
+	events := export.Events
+	result := ImportResult{Items: make([]ImportItem, len(events))}
+	taken := map[string]bool{}
+	for name := range existing {
+		taken[name] = true
+	}
+	imported := map[string]bool{} // Platform names of events taken from the file
+	ids := map[uint]uint{}        // Event IDs in the file to the IDs of the imported or kept events
+	fileIDs := make([]uint, len(events))
+	var creates, overwrites []int
+	for i := range events {
+		ev := &events[i]
+		item := &result.Items[i]
+		*item = ImportItem{Index: i, Name: ev.Name}
+		fileIDs[i] = ev.ID
+		fail := func(err error) {
+			item.Status = "invalid"
+			item.Error = err.Error()
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				item.Fields = validationErr.Errors
+			}
+			result.Failed++
+		}
+		if err := ev.Validate(); err != nil {
+			fail(err)
+			continue
+		}
+		name := sanitizeEventName(ev.Name)
+		target := existing[name]
+		switch {
+		case imported[name] && strategy != ImportRename:
+			fail(fmt.Errorf("another event in the file has the platform name %q", name))
+			continue
+		case target != nil && strategy == ImportSkip:
+			item.Status = "skipped"
+			item.ID = target.ID
+			ids[fileIDs[i]] = target.ID
+			result.Skipped++
+			continue
+		case target != nil && strategy == ImportOverwrite:
+			if _, err := ev.migrateTypes(target.fieldTypes()); err != nil {
+				fail(err)
+				continue
+			}
+			ev.Model = target.Model
+			ids[fileIDs[i]] = target.ID
+			overwrites = append(overwrites, i)
+		default:
+			if taken[name] {
+				item.RenamedFrom = ev.Name
+				ev.Name = uniqueEventName(ev.Name, taken)
+				item.Name = ev.Name
+			}
+			ev.Model = gorm.Model{}
+			creates = append(creates, i)
+		}
+		if ev.Enabled == nil {
+			ev.Enabled = boolPtr(true)
+		}
+		taken[sanitizeEventName(ev.Name)] = true
+		imported[name] = true
+	}
+
+	// Created events get their IDs on insert, so their conditions are pointed at them after.
+	remap := func(i int) {
+		ev := &events[i]
+		if id, ok := ids[ev.FireCondition.EventID]; ok {
+			ev.FireCondition.EventID = id
+			return
+		}
+		result.Items[i].Warnings = append(result.Items[i].Warnings, fmt.Sprintf("fire_condition references event %d that is neither imported nor kept, removed", ev.FireCondition.EventID))
+		ev.FireCondition = nil
+	}
+	err := eva.db.Transaction(func(tx *gorm.DB) error {
+		for _, i := range creates {
+			if err := tx.Create(&events[i]).Error; err != nil {
+				return fmt.Errorf("event %d (%s): %w", i, events[i].Name, err)
+			}
+			ids[fileIDs[i]] = events[i].ID
+		}
+		for _, i := range creates {
+			if events[i].FireCondition == nil {
+				continue
+			}
+			remap(i)
+			if err := tx.Save(&events[i]).Error; err != nil {
+				return fmt.Errorf("event %d (%s): %w", i, events[i].Name, err)
+			}
+		}
+		for _, i := range overwrites {
+			if events[i].FireCondition != nil {
+				remap(i)
+			}
+			if err := tx.Save(&events[i]).Error; err != nil {
+				return fmt.Errorf("event %d (%s): %w", i, events[i].Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	for _, i := range creates {
+		ev := &events[i]
+		item := &result.Items[i]
+		item.ID, item.Status = ev.ID, "created"
+		result.Created++
+		eva.events = append(eva.events, ev)
+		if err := eva.registerEvent(ev); err != nil {
+			eva.acapp.Syslog.Critf("Failed to register imported event %s: %v", ev.Name, err)
+			item.RegistrationError = err.Error()
+		}
+	}
+	for _, i := range overwrites {
+		ev := &events[i]
+		item := &result.Items[i]
+		item.ID, item.Status = ev.ID, "overwritten"
+		result.Overwritten++
+		eva.swapRegistered(ev)
+		item.RegistrationError = ev.RegistrationError
+	}
+	eva.acapp.Syslog.Infof("Imported events: %d created, %d overwritten, %d skipped, %d failed", result.Created, result.Overwritten, result.Skipped, result.Failed)
+	return result, nil
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+)
+
+// Expression fields compute their value from other fields of the same event. Numeric fields
+// use a small arithmetic language (+ - * / %, parentheses, numbers and field references),
+// string and bool fields use Go text/template. Fields are referenced by their name without
+// spaces, e.g. "Occupancy Count" is OccupancyCount in arithmetic and {{.OccupancyCount}} in templates.
+// References are case-insensitive in both.
+
+// isArithmeticExpression reports whether the field's expression is evaluated arithmetically.
+func (d *DataFields) isArithmeticExpression() bool {
+	return d.ValueType == IntType || d.ValueType == FloatType
+}
+
+// expressionRefs parses the field's expression and returns the sanitized keys it references.
+func (d *DataFields) expressionRefs() ([]string, error) {
+	if d.Expression == "" {
+		return nil, nil
+	}
+	if d.isArithmeticExpression() {
+		node, err := parseArithmetic(d.Expression)
+		if err != nil {
+			return nil, err
+		}
+		var refs []string
+		node.refs(&refs)
+		return refs, nil
+	}
+	return templateKeys(d.Name, d.Expression)
+}
+
+// templateKeys parses text as a template and returns the sanitized keys it references.
+func templateKeys(name, text string) ([]string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	if tmpl.Tree != nil {
+		templateRefs(tmpl.Tree.Root, &refs)
+	}
+	for i := range refs {
+		refs[i] = strings.ToLower(refs[i])
+	}
+	return refs, nil
+}
+
+// evalExpression computes the field's expression against the already generated values
+// and coerces the result to the field's ValueType.
+func (d *DataFields) evalExpression(values map[string]interface{}) (interface{}, error) {
+	if d.isArithmeticExpression() {
+		node, err := parseArithmetic(d.Expression)
+		if err != nil {
+			return nil, err
+		}
+		result, err := node.eval(func(key string) (float64, error) {
+			return toFloat(lookupValue(values, key))
+		})
+		if err != nil {
+			return nil, err
+		}
+		if d.ValueType == IntType {
+			return int(math.Round(result)), nil
+		}
+		return result, nil
+	}
+
+	out, err := renderTemplate(d.Name, d.Expression, values)
+	if err != nil {
+		return nil, err
+	}
+	if d.ValueType == BoolType {
+		return strconv.ParseBool(strings.TrimSpace(out))
+	}
+	return out, nil
+}
+
+// renderTemplate executes text as a template against the generated values, resolving
+// field identifiers case-insensitively. Missing fields are an error.
+func renderTemplate(name, text string, values map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var idents []string
+	if tmpl.Tree != nil {
+		templateRefs(tmpl.Tree.Root, &idents)
+	}
+	data := map[string]interface{}{}
+	for _, ident := range idents {
+		if value := lookupValue(values, ident); value != nil {
+			data[ident] = value
+		}
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// templateRefs collects the field identifiers ({{.Name}}) of a template parse tree as written.
+func templateRefs(node parse.Node, refs *[]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			templateRefs(child, refs)
+		}
+	case *parse.ActionNode:
+		templateRefs(n.Pipe, refs)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			templateRefs(cmd, refs)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			templateRefs(arg, refs)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 0 {
+			*refs = append(*refs, n.Ident[0])
+		}
+	case *parse.IfNode:
+		templateRefs(&n.BranchNode, refs)
+	case *parse.WithNode:
+		templateRefs(&n.BranchNode, refs)
+	case *parse.RangeNode:
+		templateRefs(&n.BranchNode, refs)
+	case *parse.BranchNode:
+		templateRefs(n.Pipe, refs)
+		templateRefs(n.List, refs)
+		templateRefs(n.ElseList, refs)
+	}
+}
+
+// lookupValue returns the generated value of key, ignoring case since explicit keys may be mixed case.
+func lookupValue(values map[string]interface{}, key string) interface{} {
+	if value, ok := values[key]; ok {
+		return value
+	}
+	for k, value := range values {
+		if strings.EqualFold(k, key) {
+			return value
+		}
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case nil:
+		return 0, fmt.Errorf("no value")
+	default:
+		return 0, fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// arithNode is a node of a parsed arithmetic expression.
+type arithNode interface {
+	eval(lookup func(key string) (float64, error)) (float64, error)
+	refs(out *[]string)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(func(string) (float64, error)) (float64, error) { return float64(n), nil }
+func (n numberNode) refs(*[]string)                                      {}
+
+type refNode string
+
+func (n refNode) eval(lookup func(string) (float64, error)) (float64, error) {
+	v, err := lookup(string(n))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", string(n), err)
+	}
+	return v, nil
+}
+func (n refNode) refs(out *[]string) { *out = append(*out, string(n)) }
+
+type unaryNode struct {
+	operand arithNode
+}
+
+func (n unaryNode) eval(lookup func(string) (float64, error)) (float64, error) {
+	v, err := n.operand.eval(lookup)
+	return -v, err
+}
+func (n unaryNode) refs(out *[]string) { n.operand.refs(out) }
+
+type binaryNode struct {
+	op          byte
+	left, right arithNode
+}
+
+func (n binaryNode) eval(lookup func(string) (float64, error)) (float64, error) {
+	l, err := n.left.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	case '/':
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case '%':
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", n.op)
+}
+func (n binaryNode) refs(out *[]string) {
+	n.left.refs(out)
+	n.right.refs(out)
+}
+
+// arithParser is a recursive descent parser for:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/" | "%") factor }
+//	factor = number | identifier | "(" expr ")" | "-" factor
+type arithParser struct {
+	input string
+	pos   int
+}
+
+func parseArithmetic(input string) (arithNode, error) {
+	p := &arithParser{input: input}
+	node, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	return node, nil
+}
+
+func (p *arithParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *arithParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *arithParser) expr() (arithNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *arithParser) term() (arithNode, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/' || op == '%'; op = p.peek() {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *arithParser) factor() (arithNode, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		node, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos)
+		}
+		p.pos++
+		return node, nil
+	case c == '-':
+		p.pos++
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{operand: operand}, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] == '.' || (p.input[p.pos] >= '0' && p.input[p.pos] <= '9')) {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return numberNode(v), nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] == '_' || unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		return refNode(strings.ToLower(p.input[start:p.pos])), nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos)
+}
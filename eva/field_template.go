@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// FieldTemplate is a reusable data field definition that can be appended to any event.
+type FieldTemplate struct {
+	gorm.Model
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Field       DataFields `gorm:"serializer:json" json:"field"`
+}
+
+// Validate checks the template name and its field definition on its own. References to other
+// fields, e.g. in expressions, are only resolved once the template is added to an event.
+func (t *FieldTemplate) Validate() error {
+	var errs []*FieldError
+	if strings.TrimSpace(t.Name) == "" {
+		errs = append(errs, &FieldError{Message: "template name must not be empty"})
+	}
+	errs = append(errs, t.Field.Validate()...)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (eva *EvaApplication) SeedFieldTemplates() {
+	var count int64
+	eva.db.Model(&FieldTemplate{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	templates := []FieldTemplate{
+		{
+			Name:        "Confidence",
+			Description: "Detection confidence, random float between 0 and 1",
+			Field:       DataFields{Name: "Confidence", Value: 0.0, ValueType: FloatType, UseRandom: true, FloatRandStart: 0, FloatRandEnd: 1, Precision: intPtr(2)},
+		},
+		{
+			Name:        "Object Type",
+			Description: "Random pick of Person, Vehicle or Unknown",
+			Field:       DataFields{Name: "Object Type", Value: "Person", ValueType: StringType, UseRandom: true, RandomStrings: []string{"Person", "Vehicle", "Unknown"}},
+		},
+		{
+			Name:        "Active",
+			Description: "Random bool, e.g. for stateful detections",
+			Field:       DataFields{Name: "Active", Value: true, ValueType: BoolType, UseRandom: true},
+		},
+		{
+			Name:        "Object Count",
+			Description: "Random int between 0 and 25",
+			Field:       DataFields{Name: "Count", Value: 0, ValueType: IntType, UseRandom: true, IntRandStart: 0, IntRandEnd: 25},
+		},
+		{
+			Name:        "Timestamp",
+			Description: "Time the event fired, RFC 3339",
+			Field:       DataFields{Name: "Timestamp", ValueType: TimestampType, TimestampFormat: TimestampRFC3339},
+		},
+	}
+
+	for i := range templates {
+		if err := eva.db.Create(&templates[i]).Error; err != nil {
+			eva.acapp.Syslog.Critf("Failed to seed field template %s: %v", templates[i].Name, err)
+		}
+	}
+	eva.acapp.Syslog.Infof("Seeded %d field templates", len(templates))
+}
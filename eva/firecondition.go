@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FireCondition lets the simulation fire an event only while the last payload another event
+// sent matches, e.g. loitering only while a person detection is active. Field is the key of
+// the value in the referenced event's payload.
+type FireCondition struct {
+	EventID uint `json:"event_id"`
+	FieldCondition
+}
+
+// validateFireCondition checks the condition on its own; a reference to a missing event is
+// flagged on read and ignored at runtime.
+func (e *EvaEvent) validateFireCondition() []*FieldError {
+	c := e.FireCondition
+	if c == nil {
+		return nil
+	}
+	fail := func(msg string) []*FieldError {
+		return []*FieldError{{Field: "fire_condition", Message: msg}}
+	}
+	if c.EventID == 0 {
+		return fail("event_id is required")
+	}
+	if e.ID != 0 && c.EventID == e.ID {
+		return fail("must not reference the event itself")
+	}
+	if err := c.validate(); err != nil {
+		return fail(strings.TrimPrefix(err.Error(), "condition "))
+	}
+	return nil
+}
+
+// fireConditionMet reports whether ev may fire: it has no condition, or the last payload of the
+// referenced event matches. Before the referenced event sent anything the condition is unmet.
+// A condition referencing a deleted event always fires and logs a warning once per run.
+func (eva *EvaApplication) fireConditionMet(ev *EvaEvent) bool {
+	c := ev.FireCondition
+	if c == nil {
+		return true
+	}
+	eva.mu.Lock()
+	ref := eva.findRegisteredEvent(c.EventID)
+	eva.mu.Unlock()
+	if ref == nil {
+		if ev.warnConditionOnce() {
+			eva.acapp.Syslog.Warnf("Fire condition of %s references deleted event %d, firing unconditionally", ev.Name, c.EventID)
+		}
+		return true
+	}
+	payload := ref.LastPayload()
+	if payload == nil {
+		return false
+	}
+	return c.met(payload)
+}
+
+// LastPayload returns the payload the event sent last, nil before its first send.
+func (e *EvaEvent) LastPayload() map[string]interface{} {
+	if e.state == nil {
+		return nil
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	return e.state.lastPayload
+}
+
+// warnConditionOnce reports whether the broken condition of the event was not warned about in
+// this run yet.
+func (e *EvaEvent) warnConditionOnce() bool {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	warn := !e.state.conditionWarned
+	e.state.conditionWarned = true
+	return warn
+}
+
+// recordUnmet counts a simulated send skipped by the fire condition.
+func (e *EvaEvent) recordUnmet() {
+	e.state.mu.Lock()
+	e.state.unmet++
+	e.state.mu.Unlock()
+}
+
+// flagFireConditions fills ConditionError of the events whose fire condition references an
+// event that no longer exists.
+func (eva *EvaApplication) flagFireConditions(events []EvaEvent) error {
+	var refs []uint
+	for _, ev := range events {
+		if ev.FireCondition != nil {
+			refs = append(refs, ev.FireCondition.EventID)
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	var found []uint
+	if err := eva.db.Model(&EvaEvent{}).Where("id IN ?", refs).Pluck("id", &found).Error; err != nil {
+		return err
+	}
+	exists := map[uint]bool{}
+	for _, id := range found {
+		exists[id] = true
+	}
+	for i := range events {
+		if c := events[i].FireCondition; c != nil && !exists[c.EventID] {
+			events[i].ConditionError = fmt.Sprintf("fire_condition references deleted event %d", c.EventID)
+		}
+	}
+	return nil
+}
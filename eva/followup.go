@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxFollowUpDepth limits follow-up chains, so events following each other in a cycle cannot
+// cascade forever.
+const maxFollowUpDepth = 8
+
+// FollowUp fires another event after its source event fired, e.g. a line crossing count a few
+// seconds after each motion detection. The delay is DelayMs, or drawn uniformly between
+// MinDelayMs and MaxDelayMs when MaxDelayMs is set.
+type FollowUp struct {
+	EventID     uint     `json:"event_id"`
+	DelayMs     int      `json:"delay_ms"`
+	MinDelayMs  int      `json:"min_delay_ms"`
+	MaxDelayMs  int      `json:"max_delay_ms"`
+	Probability *float64 `json:"probability"` // Chance the follow-up fires, nil means always
+}
+
+// validateFollowUps checks the follow-ups of the event. Whether the targets exist is only known
+// when they fire.
+func (e *EvaEvent) validateFollowUps() []*FieldError {
+	var errs []*FieldError
+	for i, f := range e.FollowUps {
+		fail := func(msg string) {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("follow-up %d: %s", i+1, msg)})
+		}
+		if f.EventID == 0 {
+			fail("event_id is required")
+		}
+		if f.DelayMs < 0 || f.MinDelayMs < 0 || f.MaxDelayMs < 0 {
+			fail("delays must not be negative")
+		}
+		if f.MaxDelayMs > 0 && f.DelayMs > 0 {
+			fail("delay_ms cannot be combined with min_delay_ms and max_delay_ms")
+		}
+		if f.MinDelayMs > 0 && f.MaxDelayMs == 0 {
+			fail("min_delay_ms requires max_delay_ms")
+		}
+		if f.MinDelayMs > f.MaxDelayMs && f.MaxDelayMs > 0 {
+			fail("min_delay_ms must not be greater than max_delay_ms")
+		}
+		if p := f.Probability; p != nil && (*p < 0 || *p > 1) {
+			fail("probability must be between 0 and 1")
+		}
+	}
+	return errs
+}
+
+// delay draws the delay of the follow-up.
+func (f *FollowUp) delay(r RandSource) time.Duration {
+	ms := f.DelayMs
+	if f.MaxDelayMs > 0 {
+		ms = f.MinDelayMs + r.Intn(f.MaxDelayMs-f.MinDelayMs+1)
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// scheduleFollowUps schedules the follow-ups of ev after it fired. depth is the length of the
+// follow-up chain that led to this fire, 0 for a fire of its own. Targets that are missing,
+// unregistered or disabled are skipped. Caller must hold eva.mu.
+func (eva *EvaApplication) scheduleFollowUps(ev *EvaEvent, depth int) {
+	if len(ev.FollowUps) == 0 {
+		return
+	}
+	if depth >= maxFollowUpDepth {
+		eva.acapp.Syslog.Warnf("Follow-ups of %s dropped, chain is longer than %d events", ev.Name, maxFollowUpDepth)
+		return
+	}
+	for i := range ev.FollowUps {
+		f := &ev.FollowUps[i]
+		var draw float64
+		var delay time.Duration
+		ev.withRand(func(r RandSource) { draw, delay = r.Float64(), f.delay(r) })
+		if f.Probability != nil && draw >= *f.Probability {
+			continue
+		}
+		target := eva.findRegisteredEvent(f.EventID)
+		if target == nil || target.EventId == 0 {
+			eva.acapp.Syslog.Warnf("Follow-up of %s skipped, event %d is not registered", ev.Name, f.EventID)
+			continue
+		}
+		if !target.IsEnabled() {
+			continue
+		}
+		eva.addTriggerJob(target, eva.scaled(delay), sendOptions{}, depth+1)
+	}
+}
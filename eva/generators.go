@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// GeneratorCategory is a built-in table of realistic values for FakeMode string fields.
+type GeneratorCategory struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Values      int    `json:"values"` // Number of distinct values the category can produce
+	generate    func(r RandSource) string
+}
+
+var (
+	firstNames = []string{
+		"Anna", "Ben", "Clara", "David", "Elena", "Felix", "Greta", "Hannah", "Isaac", "Julia",
+		"Lukas", "Maria", "Noah", "Olivia", "Paul", "Sophie", "Thomas", "Valentina", "William", "Zoe",
+	}
+	lastNames = []string{
+		"Bauer", "Garcia", "Gruber", "Hoffmann", "Huber", "Johnson", "Kim", "Martin", "Meyer", "Miller",
+		"Novak", "Rossi", "Schmidt", "Smith", "Tanaka", "Wagner", "Weber", "Williams", "Wilson", "Wolf",
+	}
+	vehicleMakes = []string{
+		"Audi", "BMW", "Citroen", "Fiat", "Ford", "Honda", "Hyundai", "Kia", "Mazda", "Mercedes-Benz",
+		"Nissan", "Opel", "Peugeot", "Renault", "Seat", "Skoda", "Tesla", "Toyota", "Volkswagen", "Volvo",
+	}
+	colors = []string{
+		"Black", "Blue", "Brown", "Gray", "Green", "Orange", "Red", "Silver", "White", "Yellow",
+	}
+	cities = []string{
+		"Amsterdam", "Berlin", "Chicago", "Graz", "Hamburg", "Linz", "London", "Lund", "Madrid", "Milan",
+		"Munich", "New York", "Paris", "Prague", "Rome", "Salzburg", "Stockholm", "Tokyo", "Vienna", "Zurich",
+	}
+)
+
+// fromTable returns a generator picking uniformly from values.
+func fromTable(values []string) func(r RandSource) string {
+	return func(r RandSource) string {
+		return RandomStringFromSlice(r, values)
+	}
+}
+
+var generatorCategories = map[string]GeneratorCategory{
+	"person_name": {
+		Name:        "person_name",
+		Description: "First and last name",
+		Values:      len(firstNames) * len(lastNames),
+		generate: func(r RandSource) string {
+			return RandomStringFromSlice(r, firstNames) + " " + RandomStringFromSlice(r, lastNames)
+		},
+	},
+	"vehicle_make": {Name: "vehicle_make", Description: "Car manufacturer", Values: len(vehicleMakes), generate: fromTable(vehicleMakes)},
+	"color":        {Name: "color", Description: "Basic color name", Values: len(colors), generate: fromTable(colors)},
+	"city":         {Name: "city", Description: "City name", Values: len(cities), generate: fromTable(cities)},
+}
+
+// GeneratorCategories lists the built-in generator categories sorted by name.
+func GeneratorCategories() []GeneratorCategory {
+	categories := make([]GeneratorCategory, 0, len(generatorCategories))
+	for _, category := range generatorCategories {
+		categories = append(categories, category)
+	}
+	slices.SortFunc(categories, func(a, b GeneratorCategory) int { return strings.Compare(a.Name, b.Name) })
+	return categories
+}
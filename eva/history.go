@@ -0,0 +1,77 @@
+package main
+
+import "time"
+
+// historySize is the number of generated values kept per field.
+const historySize = 100
+
+// HistoryEntry is one value a field was sent with.
+type HistoryEntry struct {
+	Time  time.Time   `json:"time"`
+	Value interface{} `json:"value"`
+}
+
+// valueHistory is a ring buffer of the last historySize values of a field.
+type valueHistory struct {
+	entries []HistoryEntry
+	next    int
+}
+
+func (h *valueHistory) add(entry HistoryEntry) {
+	if len(h.entries) < historySize {
+		h.entries = append(h.entries, entry)
+		return
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % historySize
+}
+
+// list returns the entries oldest first.
+func (h *valueHistory) list() []HistoryEntry {
+	out := make([]HistoryEntry, 0, len(h.entries))
+	out = append(out, h.entries[h.next:]...)
+	return append(out, h.entries[:h.next]...)
+}
+
+// recordHistory stores the value each field was sent with in values. Compound fields are
+// recorded as one object of their parts; fields omitted by a condition are skipped.
+// Caller must hold e.state.mu.
+func (e *EvaEvent) recordHistory(values map[string]interface{}, now time.Time) {
+	for i := range e.DataFields {
+		field := &e.DataFields[i]
+		key := field.SanitizedKey()
+		var value interface{}
+		if parts := field.compoundParts(); parts != nil {
+			compound := compoundValue{}
+			for _, part := range parts {
+				if v, ok := values[key+"_"+part]; ok {
+					compound[part] = v
+				}
+			}
+			if len(compound) == 0 {
+				continue
+			}
+			value = compound
+		} else if v, ok := values[key]; ok {
+			value = v
+		} else {
+			continue
+		}
+		e.state.field(key).history.add(HistoryEntry{Time: now, Value: value})
+	}
+}
+
+// FieldHistory returns the values recently sent for the field with the given key, oldest first.
+// The history is cleared whenever the simulation starts.
+func (e *EvaEvent) FieldHistory(key string) []HistoryEntry {
+	if e.state == nil {
+		return []HistoryEntry{}
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	fs, ok := e.state.fields[key]
+	if !ok {
+		return []HistoryEntry{}
+	}
+	return fs.history.list()
+}
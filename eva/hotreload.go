@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/gofiber/fiber/v3"
+)
+
+// strictRunning reports whether the request asks for ?strict=true while the simulation is
+// running, the behaviour of rejecting event edits during a run.
+func (eva *EvaApplication) strictRunning(c fiber.Ctx) bool {
+	if !fiber.Query[bool](c, "strict") {
+		return false
+	}
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	return eva.run != nil
+}
+
+// declaration returns a fingerprint of everything the platform declaration of the event depends
+// on, so an update can tell whether the event has to be declared again.
+func (e *EvaEvent) declaration(appName string) string {
+	// Set up a copy, SetupPlatformEvent resets the generator state.
+	c := *e
+	c.state = nil
+	c.SetupPlatformEvent(nil)
+	fingerprint, _ := json.Marshal(struct {
+		Own       bool
+		Levels    []TopicLevel
+		Stateless bool
+		Entries   []*acapapp.EventEntry
+	}{c.ownDeclaration(), c.topicLevels(appName), c.PlatformEvent.Stateless, c.PlatformEvent.Entries})
+	return string(fingerprint)
+}
+
+// resetFields drops the generator state of the fields, so changed field settings start fresh
+// with the next payload. The state of the run, e.g. the last sent state, is kept.
+func (e *EvaEvent) resetFields() {
+	if e.state == nil {
+		return
+	}
+	e.state.mu.Lock()
+	e.state.fields = map[string]*fieldState{}
+	e.state.rng = nil
+	e.state.mu.Unlock()
+}
+
+// applyUpdate swaps the registered copy of event for the stored update, also while the
+// simulation runs. A scheduled event is taken out of the simulation during the swap and
+// scheduled again, so a new interval applies from the next arm and new field settings from
+// the next payload. The event is only declared again when its declaration changed.
+// Caller must not hold eva.mu.
+func (eva *EvaApplication) applyUpdate(event *EvaEvent) {
+	eva.stopEventRun(event.ID)
+
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	eva.swapRegistered(event)
+}
+
+// swapRegistered is applyUpdate for events that are not scheduled in a running simulation.
+// Caller must hold eva.mu.
+func (eva *EvaApplication) swapRegistered(event *EvaEvent) {
+	registered := eva.findRegisteredEvent(event.ID)
+	if registered == nil {
+		return
+	}
+	appName := eva.acapp.Manifest.ACAPPackageConf.Setup.AppName
+	if registered.EventId == 0 || registered.declaration(appName) != event.declaration(appName) {
+		eva.reregisterEvent(event)
+	} else {
+		eva.cancelPulse(registered.ID)
+		eventID, platformEvent, state := registered.EventId, registered.PlatformEvent, registered.state
+		*registered = *event
+		registered.EventId, registered.PlatformEvent, registered.state = eventID, platformEvent, state
+		registered.resetFields()
+	}
+	if eva.run != nil && !registered.RunStatus().Completed {
+		eva.startEventRun(registered)
+	}
+}
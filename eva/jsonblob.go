@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JSON blob fields send a single string key whose value is a JSON object built from the
+// field's SubFields. Sub-fields support everything a data field does, except further nesting.
+// Their expressions and conditions reference the other sub-fields of the same blob.
+
+// blobValue generates the sub-fields and serializes them. Caller must hold e.state.mu.
+func (e *EvaEvent) blobValue(field *DataFields, key string) string {
+	values := map[string]interface{}{}
+	e.generateFields(field.SubFields, key+".", time.Now(), values, nil)
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// validateBlob checks the mode, that sub-fields are valid, unique and not nested themselves.
+func (d *DataFields) validateBlob() []*FieldError {
+	if d.Mode != JSONBlobMode {
+		return []*FieldError{{Field: d.Name, Message: fmt.Sprintf("sub_fields require the %s mode", JSONBlobMode)}}
+	}
+	if d.ValueType != StringType {
+		return []*FieldError{{Field: d.Name, Message: fmt.Sprintf("%s mode requires a string field", d.Mode)}}
+	}
+	if len(d.SubFields) == 0 {
+		return []*FieldError{{Field: d.Name, Message: fmt.Sprintf("%s fields require sub_fields", d.Mode)}}
+	}
+	var errs []*FieldError
+	seen := map[string]bool{}
+	for i := range d.SubFields {
+		sub := &d.SubFields[i]
+		name := d.Name + "." + sub.Name
+		if sub.Mode == JSONBlobMode || len(sub.SubFields) > 0 {
+			errs = append(errs, &FieldError{Field: name, Message: "sub-fields cannot be nested further"})
+			continue
+		}
+		for _, fe := range sub.Validate() {
+			fe.Field = name
+			errs = append(errs, fe)
+		}
+		for _, key := range sub.keys() {
+			if seen[key] {
+				errs = append(errs, &FieldError{Field: name, Message: fmt.Sprintf("key %s is used by another sub-field", key)})
+			}
+			seen[key] = true
+		}
+	}
+	if len(errs) == 0 {
+		if _, err := fieldOrder(d.SubFields); err != nil {
+			errs = append(errs, &FieldError{Field: d.Name, Message: err.Error()})
+		}
+	}
+	return errs
+}
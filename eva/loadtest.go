@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	maxLoadTestRate     = 500
+	maxLoadTestDuration = 30 * time.Minute
+	maxLatencySamples   = 10000           // Latencies kept for the percentiles, a random sample beyond that
+	maxWaitDuration     = 5 * time.Minute // Longest load test or chaos run a request may wait for with ?wait=true
+)
+
+var errLoadTestRunning = errors.New("load test running")
+
+// LoadTestRequest configures a load test.
+type LoadTestRequest struct {
+	EventsPerSecond float64 `json:"events_per_second"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	EventIDs        []uint  `json:"event_ids"` // Events to send, all enabled registered events when empty
+}
+
+// LoadTestResult summarizes a running or finished load test. Latencies are those of the
+// SendPlatformEvent calls in milliseconds, the percentiles are taken from a uniform sample of at
+// most maxLatencySamples sends.
+type LoadTestResult struct {
+	EventIDs     []uint    `json:"event_ids"`
+	TargetRate   float64   `json:"target_rate"`
+	StartedAt    time.Time `json:"started_at"`
+	EndedAt      time.Time `json:"ended_at,omitzero"`
+	Running      bool      `json:"running"`
+	Cancelled    bool      `json:"cancelled"`
+	Sent         int       `json:"sent"`
+	Failed       int       `json:"failed"`
+	AchievedRate float64   `json:"achieved_rate"`
+	LatencyP50   float64   `json:"latency_p50_ms"`
+	LatencyP90   float64   `json:"latency_p90_ms"`
+	LatencyP99   float64   `json:"latency_p99_ms"`
+	LatencyMax   float64   `json:"latency_max_ms"`
+}
+
+// loadTest is a running or finished load test.
+type loadTest struct {
+	cancel     context.CancelFunc
+	done       chan struct{} // Closed when the load test ended
+	mu         sync.Mutex
+	result     LoadTestResult
+	latencies  []time.Duration // Reservoir sample of the send latencies
+	maxLatency time.Duration
+}
+
+// checkWait refuses to hold a request with ?wait=true for a run longer than maxWaitDuration;
+// such runs are polled through their result endpoint instead.
+func checkWait(durationSeconds float64) error {
+	if time.Duration(durationSeconds*float64(time.Second)) > maxWaitDuration {
+		return fmt.Errorf("wait is only allowed for runs of at most %.0f seconds, poll the result instead", maxWaitDuration.Seconds())
+	}
+	return nil
+}
+
+// Validate checks the rate and duration of the request.
+func (r *LoadTestRequest) Validate() error {
+	if r.EventsPerSecond <= 0 || r.EventsPerSecond > maxLoadTestRate {
+		return fmt.Errorf("events_per_second must be greater than 0 and at most %d", maxLoadTestRate)
+	}
+	if r.DurationSeconds <= 0 || time.Duration(r.DurationSeconds*float64(time.Second)) > maxLoadTestDuration {
+		return fmt.Errorf("duration_seconds must be greater than 0 and at most %.0f", maxLoadTestDuration.Seconds())
+	}
+	return nil
+}
+
+// startLoadTest starts a load test in the background. It fails while a simulation or another
+// load test runs, or when an event of the request is not registered.
+func (eva *EvaApplication) startLoadTest(req LoadTestRequest) (*loadTest, error) {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	if eva.run != nil {
+		return nil, errSimulationRunning
+	}
+	if eva.stopping != nil {
+		return nil, errSimulationStopping
+	}
+	if eva.loadTestRunning() {
+		return nil, errLoadTestRunning
+	}
+	ids := req.EventIDs
+	if len(ids) == 0 {
+		for _, ev := range eva.events {
+			if ev.EventId != 0 && ev.IsEnabled() {
+				ids = append(ids, ev.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return nil, errNoEvents
+		}
+	}
+	for _, id := range ids {
+		if ev := eva.findRegisteredEvent(id); ev == nil || ev.EventId == 0 {
+			return nil, fmt.Errorf("event %d is not registered", id)
+		}
+	}
+
+	duration := time.Duration(req.DurationSeconds * float64(time.Second))
+	ctx, cancel := context.WithTimeout(eva.appCtx, duration)
+	lt := &loadTest{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		result: LoadTestResult{EventIDs: ids, TargetRate: req.EventsPerSecond, StartedAt: time.Now(), Running: true},
+	}
+	eva.loadTest = lt
+	eva.playbackWg.Add(1)
+	go func() {
+		defer eva.playbackWg.Done()
+		defer close(lt.done)
+		defer cancel()
+		eva.runLoadTest(ctx, lt, time.Duration(float64(time.Second)/req.EventsPerSecond))
+	}()
+	eva.acapp.Syslog.Infof("Load test started: %.1f events/s over %d events for %s", req.EventsPerSecond, len(ids), duration)
+	return lt, nil
+}
+
+// runLoadTest sends the events round-robin, one every gap, until ctx ends. A tick that comes
+// while a send is still in flight is dropped, so a slow platform shows as a lower achieved rate.
+func (eva *EvaApplication) runLoadTest(ctx context.Context, lt *loadTest, gap time.Duration) {
+	ticker := time.NewTicker(gap)
+	defer ticker.Stop()
+	ids := lt.result.EventIDs
+	for i := 0; ; i++ {
+		latency, err := eva.loadTestSend(ids[i%len(ids)])
+		lt.record(latency, err)
+		select {
+		case <-ctx.Done():
+			lt.finish(errors.Is(ctx.Err(), context.Canceled))
+			result := lt.Result()
+			eva.acapp.Syslog.Infof("Load test ended: %d sent, %d failed, %.1f events/s", result.Sent, result.Failed, result.AchievedRate)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadTestSend triggers the event with the given DB ID and returns the latency of the send. The
+// send bypasses the global rate cap: the load test sets its own rate, and a send deferred by the
+// cap would report no latency.
+func (eva *EvaApplication) loadTestSend(dbID uint) (time.Duration, error) {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	ev := eva.findRegisteredEvent(dbID)
+	if ev == nil || ev.EventId == 0 {
+		return 0, fmt.Errorf("event %d is not registered", dbID)
+	}
+	start := time.Now()
+	err := eva.triggerEvent(ev, sendOptions{force: true, uncapped: true})
+	return time.Since(start), err
+}
+
+// loadTestRunning reports whether a load test runs. Caller must hold eva.mu.
+func (eva *EvaApplication) loadTestRunning() bool {
+	if eva.loadTest == nil {
+		return false
+	}
+	select {
+	case <-eva.loadTest.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stopLoadTest cancels the running load test and waits for it. It returns false when none runs.
+func (eva *EvaApplication) stopLoadTest() bool {
+	eva.mu.Lock()
+	lt := eva.loadTest
+	running := eva.loadTestRunning()
+	eva.mu.Unlock()
+	if !running {
+		return false
+	}
+	lt.cancel()
+	<-lt.done
+	return true
+}
+
+// record counts a send of the load test.
+func (lt *loadTest) record(latency time.Duration, err error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if err != nil {
+		lt.result.Failed++
+		return
+	}
+	lt.result.Sent++
+	lt.maxLatency = max(lt.maxLatency, latency)
+	if len(lt.latencies) < maxLatencySamples {
+		lt.latencies = append(lt.latencies, latency)
+	} else if i := rand.Intn(lt.result.Sent); i < maxLatencySamples {
+		lt.latencies[i] = latency
+	}
+}
+
+// finish marks the load test as ended.
+func (lt *loadTest) finish(cancelled bool) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.result.Running = false
+	lt.result.Cancelled = cancelled
+	lt.result.EndedAt = time.Now()
+}
+
+// Result returns the summary so far, with the achieved rate and latency percentiles.
+func (lt *loadTest) Result() LoadTestResult {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	result := lt.result
+	end := result.EndedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if elapsed := end.Sub(result.StartedAt).Seconds(); elapsed > 0 {
+		result.AchievedRate = float64(result.Sent) / elapsed
+	}
+	if len(lt.latencies) > 0 {
+		sorted := slices.Clone(lt.latencies)
+		slices.Sort(sorted)
+		percentile := func(p float64) float64 {
+			return durationMs(sorted[min(int(p*float64(len(sorted))), len(sorted)-1)])
+		}
+		result.LatencyP50 = percentile(0.5)
+		result.LatencyP90 = percentile(0.9)
+		result.LatencyP99 = percentile(0.99)
+		result.LatencyMax = durationMs(lt.maxLatency)
+	}
+	return result
+}
+
+// durationMs converts d to fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadTestSendBypassesRateCap(t *testing.T) {
+	eva, platform := newTestEva(t)
+	eva.rateCap.configure(1, RateCapDelay)
+	eva.events = []*EvaEvent{testIntervalEvent(eva, 1, 0)}
+
+	for range 3 {
+		if _, err := eva.loadTestSend(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sends := len(platform.sendsOf(1)); sends != 3 {
+		t.Errorf("%d sends went out, want 3 beyond the cap of 1/s", sends)
+	}
+	if pending := eva.pendingDelayedSends(); pending != 0 {
+		t.Errorf("%d sends delayed by the cap", pending)
+	}
+}
+
+func TestLoadTestLatencySample(t *testing.T) {
+	lt := &loadTest{}
+	sends := maxLatencySamples + 5000
+	for i := range sends {
+		lt.record(time.Duration(i+1)*time.Microsecond, nil)
+	}
+	if len(lt.latencies) != maxLatencySamples {
+		t.Errorf("%d latencies kept, want %d", len(lt.latencies), maxLatencySamples)
+	}
+	result := lt.Result()
+	if result.Sent != sends {
+		t.Errorf("sent = %d, want %d", result.Sent, sends)
+	}
+	if want := durationMs(time.Duration(sends) * time.Microsecond); result.LatencyMax != want {
+		t.Errorf("latency_max_ms = %v, want %v", result.LatencyMax, want)
+	}
+	// The median of the sample stays near the median of all sends.
+	if median := durationMs(time.Duration(sends/2) * time.Microsecond); result.LatencyP50 < median*0.9 || result.LatencyP50 > median*1.1 {
+		t.Errorf("latency_p50_ms = %v, want about %v", result.LatencyP50, median)
+	}
+}
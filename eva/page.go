@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// maxPageLimit is the largest accepted page size of a list.
+const maxPageLimit = 1000
+
+// Page is a window into a list, read from the "limit" and "offset" query parameters. A zero
+// limit means all rows.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// parsePage reads the page of a list request, defaultLimit when no limit is given.
+func parsePage(c fiber.Ctx, defaultLimit int) (Page, error) {
+	page := Page{Limit: defaultLimit}
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"limit", &page.Limit}, {"offset", &page.Offset}} {
+		q := c.Query(param.name)
+		if q == "" {
+			continue
+		}
+		n, err := strconv.Atoi(q)
+		if err != nil || n < 0 {
+			return Page{}, fmt.Errorf("invalid %s %q, expected a non-negative integer", param.name, q)
+		}
+		*param.value = n
+	}
+	if page.Limit > maxPageLimit {
+		return Page{}, fmt.Errorf("limit must be at most %d", maxPageLimit)
+	}
+	return page, nil
+}
+
+// apply limits query to the page.
+func (p Page) apply(query *gorm.DB) *gorm.DB {
+	if p.Limit > 0 {
+		query = query.Limit(p.Limit)
+	}
+	if p.Offset > 0 {
+		query = query.Offset(p.Offset)
+	}
+	return query
+}
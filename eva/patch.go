@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// readOnlyKeys are the top-level keys of an event a patch must not change.
+var readOnlyKeys = map[string]bool{"ID": true, "CreatedAt": true, "UpdatedAt": true, "DeletedAt": true}
+
+// patchAliases maps alternative spellings of patchable keys to the event's JSON keys.
+var patchAliases = map[string]string{"data_fields": "DataFields"}
+
+// patched returns a copy of the event with the top-level keys of body replaced: a key that is
+// present replaces the value, including slices like DataFields as a whole, and null clears it;
+// absent keys keep their value. Unknown and read-only keys are rejected.
+func (e *EvaEvent) patched(body []byte) (*EvaEvent, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, err
+	}
+	current, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(current, &merged); err != nil {
+		return nil, err
+	}
+	patchable, err := patchableKeys()
+	if err != nil {
+		return nil, err
+	}
+	var rejected []string
+	for key, value := range patch {
+		if alias, ok := patchAliases[key]; ok {
+			key = alias
+		}
+		if !patchable[key] {
+			rejected = append(rejected, key)
+			continue
+		}
+		merged[key] = value
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return nil, fmt.Errorf("unknown or read-only fields: %s", strings.Join(rejected, ", "))
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var out EvaEvent
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	out.Model = e.Model
+	return &out, nil
+}
+
+// patchableKeys returns the stored top-level keys of an event, those a PUT body sets.
+// Keys filled on read are left out as they are omitted from an empty event.
+func patchableKeys() (map[string]bool, error) {
+	data, err := json.Marshal(EvaEvent{})
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for key := range fields {
+		if !readOnlyKeys[key] {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
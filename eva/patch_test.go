@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEventPatched(t *testing.T) {
+	base := func() *EvaEvent {
+		ev := &EvaEvent{
+			Name:            "Person Detection",
+			UseInterval:     boolPtr(true),
+			IntervalSeconds: 5,
+			Stateless:       boolPtr(true),
+			DataFields:      []DataFields{{Name: "Count", ValueType: IntType}, {Name: "Zone", ValueType: StringType}},
+		}
+		ev.ID = 7
+		return ev
+	}
+	tests := []struct {
+		name  string
+		body  string
+		check func(t *testing.T, got *EvaEvent)
+		err   string
+	}{
+		{
+			name: "change only interval",
+			body: `{"interval_seconds": 10}`,
+			check: func(t *testing.T, got *EvaEvent) {
+				if got.IntervalSeconds != 10 {
+					t.Errorf("interval_seconds = %d, want 10", got.IntervalSeconds)
+				}
+				if got.Name != "Person Detection" || len(got.DataFields) != 2 || got.UseInterval == nil || !*got.UseInterval {
+					t.Errorf("other settings changed: %+v", got)
+				}
+			},
+		},
+		{
+			name: "change only name",
+			body: `{"name": "Vehicle Detection"}`,
+			check: func(t *testing.T, got *EvaEvent) {
+				if got.Name != "Vehicle Detection" {
+					t.Errorf("name = %q, want Vehicle Detection", got.Name)
+				}
+				if got.IntervalSeconds != 5 || len(got.DataFields) != 2 {
+					t.Errorf("other settings changed: %+v", got)
+				}
+			},
+		},
+		{
+			name: "clear data_fields with an empty list",
+			body: `{"data_fields": []}`,
+			check: func(t *testing.T, got *EvaEvent) {
+				if got.DataFields == nil || len(got.DataFields) != 0 {
+					t.Errorf("data fields = %#v, want an empty list", got.DataFields)
+				}
+			},
+		},
+		{
+			name: "clear data_fields with null",
+			body: `{"data_fields": null}`,
+			check: func(t *testing.T, got *EvaEvent) {
+				if got.DataFields != nil {
+					t.Errorf("data fields = %#v, want nil", got.DataFields)
+				}
+			},
+		},
+		{
+			name: "replace data fields as a whole",
+			body: `{"DataFields": [{"name": "Speed", "value_type": "float"}]}`,
+			check: func(t *testing.T, got *EvaEvent) {
+				if len(got.DataFields) != 1 || got.DataFields[0].Name != "Speed" {
+					t.Errorf("data fields = %+v, want only Speed", got.DataFields)
+				}
+			},
+		},
+		{
+			name: "null clears a pointer setting",
+			body: `{"use_interval": null, "stateless": false}`,
+			check: func(t *testing.T, got *EvaEvent) {
+				if got.UseInterval != nil {
+					t.Errorf("use_interval = %v, want unset", *got.UseInterval)
+				}
+				if got.Stateless == nil || *got.Stateless {
+					t.Errorf("stateless = %v, want false", got.Stateless)
+				}
+			},
+		},
+		{
+			name: "keeps the ID",
+			body: `{}`,
+			check: func(t *testing.T, got *EvaEvent) {
+				if got.ID != 7 {
+					t.Errorf("ID = %d, want 7", got.ID)
+				}
+			},
+		},
+		{name: "reject the ID", body: `{"ID": 8}`, err: "ID"},
+		{name: "reject timestamps", body: `{"CreatedAt": "2026-01-01T00:00:00Z", "DeletedAt": null}`, err: "CreatedAt, DeletedAt"},
+		{name: "reject unknown keys", body: `{"interval": 3}`, err: "interval"},
+		{name: "reject a non-object body", body: `[1]`, err: "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := base()
+			got, err := ev.patched([]byte(tt.body))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one naming %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, got)
+			if len(ev.DataFields) != 2 || ev.Name != "Person Detection" || ev.IntervalSeconds != 5 {
+				t.Errorf("the patched event was changed: %+v", ev)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// playback is a background run of a scenario or recording.
+type playback struct {
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the run finished or was stopped
+}
+
+// finished reports whether the run returned.
+func (p *playback) finished() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// playbacks tracks the background runs of one kind by ID. The zero value is ready to use.
+type playbacks struct {
+	mu   sync.Mutex
+	runs map[uint]*playback
+}
+
+// start runs play in the background under id with a context derived from parent. It returns
+// false when id is still playing.
+func (p *playbacks) start(parent context.Context, wg *sync.WaitGroup, id uint, play func(ctx context.Context)) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if run, ok := p.runs[id]; ok && !run.finished() {
+		return false
+	}
+	ctx, cancel := context.WithCancel(parent)
+	run := &playback{cancel: cancel, done: make(chan struct{})}
+	if p.runs == nil {
+		p.runs = map[uint]*playback{}
+	}
+	p.runs[id] = run
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(run.done)
+		defer cancel()
+		play(ctx)
+	}()
+	return true
+}
+
+// stop cancels the run of id and waits for it. It returns false when id was not playing.
+func (p *playbacks) stop(id uint) bool {
+	p.mu.Lock()
+	run, ok := p.runs[id]
+	delete(p.runs, id)
+	p.mu.Unlock()
+	if !ok || run.finished() {
+		return false
+	}
+	run.cancel()
+	<-run.done
+	return true
+}
+
+// running reports whether id is playing.
+func (p *playbacks) running(id uint) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	run, ok := p.runs[id]
+	return ok && !run.finished()
+}
+
+// stopAll cancels all runs and waits for them. It returns how many were still playing.
+func (p *playbacks) stopAll() int {
+	p.mu.Lock()
+	runs := p.runs
+	p.runs = nil
+	p.mu.Unlock()
+	stopped := 0
+	for _, run := range runs {
+		if !run.finished() {
+			stopped++
+		}
+		run.cancel()
+		<-run.done
+	}
+	return stopped
+}
@@ -0,0 +1,35 @@
+package main
+
+import "time"
+
+// validateFireProbability checks that FireProbability is a probability.
+func (e *EvaEvent) validateFireProbability() []*FieldError {
+	if p := e.FireProbability; p != nil && (*p < 0 || *p > 1) {
+		return []*FieldError{{Message: "fire_probability must be between 0 and 1", Value: *p}}
+	}
+	return nil
+}
+
+// rollFire decides whether a simulated tick of the event fires, with the probability of the
+// active time band or FireProbability. Without either every tick fires; a seeded event or run draws from its seeded generator so runs repeat the same ticks.
+func (e *EvaEvent) rollFire() bool {
+	p := e.fireProbability(time.Now())
+	if p >= 1 {
+		return true
+	}
+	var draw float64
+	e.withRand(func(r RandSource) { draw = r.Float64() })
+	return draw < p
+}
+
+// dropTick counts a simulated tick skipped by FireProbability. With AdvanceSkippedTicks the
+// data fields are generated and discarded, so counters and sequential fields move on as if
+// the tick had fired.
+func (e *EvaEvent) dropTick() {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.dropped++
+	if e.AdvanceSkippedTicks != nil && *e.AdvanceSkippedTicks {
+		e.generateFields(e.DataFields, "", time.Now(), map[string]interface{}{}, nil)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// pendingPulse is the scheduled fall of a pulsed stateful event.
+type pendingPulse struct {
+	EventID uint      `json:"event_id"`
+	Name    string    `json:"event"`
+	DueAt   time.Time `json:"due_at"`
+	timer   *time.Timer
+}
+
+// pulseDuration returns how long a pulse of the event stays active, zero when it does not pulse.
+func (e *EvaEvent) pulseDuration() time.Duration {
+	if e.PulseDurationMs <= 0 || !e.IsStateful() || e.stateKey() == "" {
+		return 0
+	}
+	return time.Duration(e.PulseDurationMs) * time.Millisecond
+}
+
+// pulse sends the active state of ev now and schedules the inactive state after its pulse
+// duration. Triggering an event with a pending pulse restarts the pulse. The fall repeats the
+// source values of the rise. When the rise fails no fall is scheduled, and a pending pulse keeps
+// its fall.
+func (eva *EvaApplication) pulse(ev *EvaEvent, opts sendOptions) error {
+	eva.pulseMu.Lock()
+	defer eva.pulseMu.Unlock()
+	if opts.sources == nil {
+		opts.sources = ev.pickSources()
+	}
+	if err := eva.sendState(ev, true, opts); err != nil {
+		return err
+	}
+	if pending, ok := eva.pulses[ev.ID]; ok {
+		pending.timer.Stop()
+		delete(eva.pulses, ev.ID)
+	}
+
+	d := eva.scaled(ev.pulseDuration())
+	pending := &pendingPulse{EventID: ev.ID, Name: ev.Name, DueAt: time.Now().Add(d)}
+	pending.timer = time.AfterFunc(d, func() {
+		eva.pulseMu.Lock()
+		defer eva.pulseMu.Unlock()
+		// A cancelled or restarted pulse is no longer in the map.
+		if eva.pulses[ev.ID] != pending {
+			return
+		}
+		delete(eva.pulses, ev.ID)
+		eva.sendState(ev, false, sendOptions{sources: opts.sources})
+	})
+	if eva.pulses == nil {
+		eva.pulses = map[uint]*pendingPulse{}
+	}
+	eva.pulses[ev.ID] = pending
+	return nil
+}
+
+// cancelPulse drops the pending fall of the event with the given DB ID, if any.
+func (eva *EvaApplication) cancelPulse(dbID uint) {
+	eva.pulseMu.Lock()
+	defer eva.pulseMu.Unlock()
+	if pending, ok := eva.pulses[dbID]; ok {
+		pending.timer.Stop()
+		delete(eva.pulses, dbID)
+	}
+}
+
+// cancelAllPulses drops every pending fall and returns how many there were.
+func (eva *EvaApplication) cancelAllPulses() int {
+	eva.pulseMu.Lock()
+	defer eva.pulseMu.Unlock()
+	cancelled := len(eva.pulses)
+	for dbID, pending := range eva.pulses {
+		pending.timer.Stop()
+		delete(eva.pulses, dbID)
+	}
+	return cancelled
+}
+
+// PendingPulses lists the scheduled falls ordered by due time.
+func (eva *EvaApplication) PendingPulses() []pendingPulse {
+	eva.pulseMu.Lock()
+	defer eva.pulseMu.Unlock()
+	pulses := make([]pendingPulse, 0, len(eva.pulses))
+	for _, pending := range eva.pulses {
+		pulses = append(pulses, *pending)
+	}
+	slices.SortFunc(pulses, func(a, b pendingPulse) int { return a.DueAt.Compare(b.DueAt) })
+	return pulses
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPulseFailedRiseSchedulesNoFall(t *testing.T) {
+	eva, _ := newTestEva(t)
+	eva.rateCap.configure(1, RateCapDrop)
+	var events []*EvaEvent
+	for id := uint(1); id <= 2; id++ {
+		ev := testIntervalEvent(eva, id, 0)
+		ev.Stateless = boolPtr(false)
+		ev.PulseDurationMs = 60000
+		ev.DataFields = []DataFields{{Name: "Active", ValueType: BoolType}}
+		ev.SetupPlatformEvent(eva)
+		ev.EventId = int(id)
+		events = append(events, ev)
+	}
+	eva.events = events
+
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	if err := eva.triggerEvent(events[0], sendOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pending := eva.PendingPulses()
+	// Both rises are beyond the cap of one send per second.
+	for _, ev := range events {
+		if err := eva.triggerEvent(ev, sendOptions{}); !errors.Is(err, errRateCapped) {
+			t.Fatalf("trigger of %s: got %v, want %v", ev.Name, err, errRateCapped)
+		}
+	}
+	after := eva.PendingPulses()
+	if len(after) != 1 || after[0].EventID != 1 || !after[0].DueAt.Equal(pending[0].DueAt) {
+		t.Errorf("pending pulses after the failed rises = %+v, want the first pulse unchanged", after)
+	}
+	eva.cancelAllPulses()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietWindow is a local time window in which the simulation does not fire. Start and End are
+// "HH:MM"; a window whose end is before its start crosses midnight. Days lists the weekdays
+// (SUN..SAT) the window starts on, empty means every day.
+type QuietWindow struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
+}
+
+// contains reports whether the local time of now falls inside the window.
+func (w *QuietWindow) contains(now time.Time) bool {
+	return inDailyWindow(w.Start, w.End, w.Days, now)
+}
+
+// inQuietHours reports whether now falls inside one of windows.
+func inQuietHours(windows []QuietWindow, now time.Time) bool {
+	for i := range windows {
+		if windows[i].contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateQuietHours checks the window times and days.
+func validateQuietHours(windows []QuietWindow) []*FieldError {
+	var errs []*FieldError
+	for i, w := range windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("quiet window %d: %v", i+1, err)})
+			continue
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("quiet window %d: %v", i+1, err)})
+			continue
+		}
+		if start == end {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("quiet window %d: start and end must differ", i+1)})
+		}
+		if err := validateWeekdays(w.Days); err != nil {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("quiet window %d: %v", i+1, err)})
+		}
+	}
+	return errs
+}
+
+// validateQuietHours checks the quiet windows of the event.
+func (e *EvaEvent) validateQuietHours() []*FieldError {
+	return validateQuietHours(e.QuietHours)
+}
+
+// isQuiet reports whether the global or the event's quiet hours hold the simulated sends of ev
+// at now.
+func (eva *EvaApplication) isQuiet(ev *EvaEvent, now time.Time) bool {
+	eva.mu.Lock()
+	global := eva.quietHours
+	eva.mu.Unlock()
+	return inQuietHours(global, now) || inQuietHours(ev.QuietHours, now)
+}
+
+// recordQuiet counts a simulated send held by quiet hours.
+func (e *EvaEvent) recordQuiet() {
+	e.state.mu.Lock()
+	e.state.quiet++
+	e.state.mu.Unlock()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// defaultRampStartFactor is the starting rate factor of a ramp without ramp_start_factor.
+const defaultRampStartFactor = 0.1
+
+// ramp raises the fire rate of interval events from startFactor times their configured rate
+// to the full rate over duration. The rate is multiplied by the same amount per unit of time,
+// so it grows slowly at first and faster towards the end.
+type ramp struct {
+	startedAt   time.Time
+	duration    time.Duration
+	startFactor float64
+}
+
+// parseRamp reads the optional "ramp_duration_seconds" and "ramp_start_factor" of a simulation
+// start from the query or JSON body. A zero duration means no ramp.
+func parseRamp(c fiber.Ctx) (ramp, error) {
+	seconds := fiber.Query[float64](c, "ramp_duration_seconds")
+	factor := fiber.Query[float64](c, "ramp_start_factor")
+	if seconds == 0 && len(c.Body()) > 0 {
+		var body struct {
+			RampDurationSeconds float64 `json:"ramp_duration_seconds"`
+			RampStartFactor     float64 `json:"ramp_start_factor"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return ramp{}, err
+		}
+		seconds, factor = body.RampDurationSeconds, body.RampStartFactor
+	}
+	if seconds < 0 {
+		return ramp{}, fmt.Errorf("ramp_duration_seconds must not be negative")
+	}
+	if factor < 0 || factor > 1 {
+		return ramp{}, fmt.Errorf("ramp_start_factor must be between 0 and 1")
+	}
+	if seconds == 0 {
+		return ramp{}, nil
+	}
+	if factor == 0 {
+		factor = defaultRampStartFactor
+	}
+	return ramp{duration: time.Duration(seconds * float64(time.Second)), startFactor: factor}, nil
+}
+
+// factor returns the rate factor at now: startFactor when the ramp starts, 1 once it is over
+// or without a ramp.
+func (r ramp) factor(now time.Time) float64 {
+	if r.duration <= 0 {
+		return 1
+	}
+	progress := float64(now.Sub(r.startedAt)) / float64(r.duration)
+	if progress >= 1 {
+		return 1
+	}
+	return math.Pow(r.startFactor, 1-max(progress, 0))
+}
+
+// ramped stretches the interval gap d by the ramp factor of the run. The gap is drawn first,
+// so random intervals and jitter keep their spread relative to the current rate.
+func (r *simulationRun) ramped(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / r.ramp.factor(time.Now()))
+}
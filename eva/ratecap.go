@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+)
+
+// RateCapPolicy tells what happens to sends beyond the global rate cap.
+type RateCapPolicy string
+
+const (
+	RateCapDrop  RateCapPolicy = "drop"  // Sends beyond the cap are dropped
+	RateCapDelay RateCapPolicy = "delay" // Sends beyond the cap wait for a later second
+)
+
+const (
+	defaultMaxEventsPerSecond = 100
+	maxEventsPerSecondLimit   = 10000
+	maxRateCapDelay           = 2 * time.Second // Sends that would wait longer are dropped
+)
+
+var errRateCapped = errors.New("dropped by the global rate cap")
+
+// rateCap limits the sends of all events to a number per second. Each second is a window;
+// with the delay policy a send may reserve a slot in a later window and is made then.
+type rateCap struct {
+	mu          sync.Mutex
+	limit       int
+	policy      RateCapPolicy
+	windowStart time.Time
+	count       int  // Sends in the current window and reserved in later ones
+	dropped     int  // Sends dropped since the counters were reset
+	delayed     int  // Sends delayed since the counters were reset
+	warned      bool // A warning was logged in the current window
+}
+
+// RateCapStatus reports the rate cap in the simulation status.
+type RateCapStatus struct {
+	MaxEventsPerSecond int           `json:"max_events_per_second"`
+	Policy             RateCapPolicy `json:"policy"`
+	Dropped            int           `json:"dropped"`
+	Delayed            int           `json:"delayed"`
+}
+
+// RateCapSettings is the body of PUT /settings/rate-cap.
+type RateCapSettings struct {
+	MaxEventsPerSecond int           `json:"max_events_per_second"`
+	Policy             RateCapPolicy `json:"policy"`
+}
+
+// Validate checks the limit and the policy.
+func (s RateCapSettings) Validate() []*FieldError {
+	var errs []*FieldError
+	if s.MaxEventsPerSecond < 1 || s.MaxEventsPerSecond > maxEventsPerSecondLimit {
+		errs = append(errs, &FieldError{Field: "max_events_per_second", Message: fmt.Sprintf("must be between 1 and %d", maxEventsPerSecondLimit), Value: s.MaxEventsPerSecond})
+	}
+	if s.Policy != RateCapDrop && s.Policy != RateCapDelay {
+		errs = append(errs, &FieldError{Field: "policy", Message: "unknown policy", Value: s.Policy, Allowed: []string{string(RateCapDrop), string(RateCapDelay)}})
+	}
+	return errs
+}
+
+// configure applies the limit and policy, the counters are kept.
+func (r *rateCap) configure(limit int, policy RateCapPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limit = limit
+	r.policy = policy
+}
+
+// reserve takes a send slot. It returns how long the send has to wait for its slot, and false
+// when the send is dropped. The second return value reports whether the caller should warn.
+func (r *rateCap) reserve(now time.Time) (time.Duration, bool, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limit <= 0 {
+		return 0, true, false
+	}
+	if elapsed := now.Sub(r.windowStart); elapsed >= time.Second {
+		seconds := int(elapsed / time.Second)
+		r.windowStart = r.windowStart.Add(time.Duration(seconds) * time.Second)
+		r.count = max(r.count-seconds*r.limit, 0)
+		r.warned = false
+		if r.count == 0 {
+			r.windowStart = now
+		}
+	}
+	if r.count < r.limit {
+		r.count++
+		return 0, true, false
+	}
+	warn := !r.warned
+	r.warned = true
+	if r.policy == RateCapDelay {
+		wait := r.windowStart.Add(time.Duration(r.count/r.limit) * time.Second).Sub(now)
+		if wait <= maxRateCapDelay {
+			r.count++
+			r.delayed++
+			return wait, true, warn
+		}
+	}
+	r.dropped++
+	return 0, false, warn
+}
+
+// status returns the settings and counters.
+func (r *rateCap) status() RateCapStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RateCapStatus{MaxEventsPerSecond: r.limit, Policy: r.policy, Dropped: r.dropped, Delayed: r.delayed}
+}
+
+// resetCounts restarts the dropped and delayed counts, e.g. when the simulation starts.
+func (r *rateCap) resetCounts() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dropped = 0
+	r.delayed = 0
+}
+
+// capSend applies the global rate cap to a send of ev. It returns how long a delayed send has
+// to wait for its slot, and errRateCapped when the send is dropped. It never blocks, so it may
+// be called with eva.mu held.
+func (eva *EvaApplication) capSend(ev *EvaEvent) (time.Duration, error) {
+	wait, ok, warn := eva.rateCap.reserve(time.Now())
+	if warn {
+		action := "dropped"
+		if ok {
+			action = "delayed"
+		}
+		eva.acapp.Syslog.Warnf("Global rate cap of %d events/s reached by %s, sends are %s", eva.rateCap.status().MaxEventsPerSecond, ev.Name, action)
+	}
+	if !ok {
+		return 0, errRateCapped
+	}
+	return wait, nil
+}
+
+// delayedSend is a send the rate cap queued for a later slot.
+type delayedSend struct {
+	timer *time.Timer
+}
+
+// queueDelayed makes the send of ev after wait. It is dropped when a stop cancelled it meanwhile,
+// or when ev was deleted or unregistered.
+func (eva *EvaApplication) queueDelayed(ev *EvaEvent, wait time.Duration, build func() acapapp.KeyValueMap, sent func(acapapp.KeyValueMap)) {
+	eva.delayMu.Lock()
+	defer eva.delayMu.Unlock()
+	pending := &delayedSend{}
+	pending.timer = time.AfterFunc(wait, func() {
+		eva.mu.Lock()
+		defer eva.mu.Unlock()
+		eva.delayMu.Lock()
+		// A cancelled send is no longer in the map.
+		queued := eva.delayed[pending]
+		delete(eva.delayed, pending)
+		eva.delayMu.Unlock()
+		if !queued {
+			return
+		}
+		if eva.findRegisteredEvent(ev.ID) != ev || ev.EventId == 0 {
+			eva.acapp.Syslog.Warnf("Dropped delayed send of %s, the event is no longer registered", ev.Name)
+			return
+		}
+		if err := eva.deliver(ev, build, sent); err != nil {
+			eva.acapp.Syslog.Critf("Delayed send of %s failed: %v", ev.Name, err)
+		}
+	})
+	if eva.delayed == nil {
+		eva.delayed = map[*delayedSend]bool{}
+	}
+	eva.delayed[pending] = true
+}
+
+// pendingDelayedSends returns how many sends wait for their rate cap slot.
+func (eva *EvaApplication) pendingDelayedSends() int {
+	eva.delayMu.Lock()
+	defer eva.delayMu.Unlock()
+	return len(eva.delayed)
+}
+
+// cancelDelayedSends drops every send waiting for its rate cap slot and returns how many there
+// were, so nothing queued before a stop goes out after its inactive states.
+func (eva *EvaApplication) cancelDelayedSends() int {
+	eva.delayMu.Lock()
+	defer eva.delayMu.Unlock()
+	cancelled := len(eva.delayed)
+	for pending := range eva.delayed {
+		pending.timer.Stop()
+		delete(eva.delayed, pending)
+	}
+	return cancelled
+}
+
+// expectedRate estimates the sends per second of all simulated events at the given time scale:
+// one per interval, two per cycle of a duty cycle or active duration. Cron events are left out.
+// Caller must hold eva.mu.
+func (eva *EvaApplication) expectedRate(scale float64) float64 {
+	rate := 0.0
+	for _, ev := range eva.events {
+		if !ev.IsEnabled() || ev.UseInterval == nil || !*ev.UseInterval {
+			continue
+		}
+		switch {
+		case ev.hasDutyCycle():
+			rate += 2 / float64(ev.ActiveSeconds+ev.InactiveSeconds)
+		case ev.CronSpec != "":
+		case ev.hasInterval():
+			interval := ev.baseInterval().Seconds()
+			if ev.usesRandomInterval() {
+				interval = float64(ev.IntervalMinSeconds+ev.IntervalMaxSeconds) / 2
+			}
+			sends := 1.0
+			if ev.ActiveDurationSeconds > 0 || ev.pulseDuration() > 0 {
+				sends = 2
+			}
+			rate += sends / interval
+		}
+	}
+	return rate * scale
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/Cacsjep/goxis/pkg/axevent"
+	"gorm.io/gorm"
+)
+
+// Recording is a captured session of event sends that can be replayed with the same payloads
+// and timing, e.g. for reproducible end-to-end tests.
+type Recording struct {
+	gorm.Model
+	Name       string         `json:"name"`
+	StartedAt  time.Time      `json:"started_at"`
+	DurationMs int64          `json:"duration_ms"` // From start to stop of the recording
+	Sends      []RecordedSend `gorm:"serializer:json" json:"sends"`
+	Replaying  bool           `gorm:"-" json:"replaying"` // Filled on read
+}
+
+// RecordedSend is a single send of a recording.
+type RecordedSend struct {
+	EventID  uint                   `json:"event_id"`
+	Event    string                 `json:"event"`
+	OffsetMs int64                  `json:"offset_ms"` // Time since the recording started
+	Payload  map[string]interface{} `json:"payload"`
+}
+
+// recorder captures the sends of an active recording.
+type recorder struct {
+	startedAt time.Time
+	sends     []RecordedSend
+}
+
+// startRecording begins capturing every send. It returns false when a recording is active.
+func (eva *EvaApplication) startRecording() (time.Time, bool) {
+	eva.recordMu.Lock()
+	defer eva.recordMu.Unlock()
+	if eva.recorder != nil {
+		return time.Time{}, false
+	}
+	eva.recorder = &recorder{startedAt: time.Now(), sends: []RecordedSend{}}
+	return eva.recorder.startedAt, true
+}
+
+// stopRecording ends the active recording and returns it unsaved, nil when none is active.
+func (eva *EvaApplication) stopRecording(name string) *Recording {
+	eva.recordMu.Lock()
+	defer eva.recordMu.Unlock()
+	if eva.recorder == nil {
+		return nil
+	}
+	r := eva.recorder
+	eva.recorder = nil
+	if name == "" {
+		name = "Recording " + r.startedAt.Format("2006-01-02 15:04:05")
+	}
+	return &Recording{Name: name, StartedAt: r.startedAt, DurationMs: time.Since(r.startedAt).Milliseconds(), Sends: r.sends}
+}
+
+// record adds a sent payload of ev to the active recording, if any.
+func (eva *EvaApplication) record(ev *EvaEvent, payload acapapp.KeyValueMap) {
+	eva.recordMu.Lock()
+	defer eva.recordMu.Unlock()
+	if eva.recorder == nil || payload == nil {
+		return
+	}
+	eva.recorder.sends = append(eva.recorder.sends, RecordedSend{
+		EventID:  ev.ID,
+		Event:    ev.Name,
+		OffsetMs: time.Since(eva.recorder.startedAt).Milliseconds(),
+		Payload:  payload,
+	})
+}
+
+// restoreTypes converts the JSON numbers of a stored payload back to int where ev declares an int.
+func (e *EvaEvent) restoreTypes(payload map[string]interface{}) acapapp.KeyValueMap {
+	kvmap := acapapp.KeyValueMap{}
+	for key, value := range payload {
+		kvmap[key] = value
+	}
+	for _, entry := range e.PlatformEvent.Entries {
+		if f, ok := kvmap[entry.Key].(float64); ok && entry.ValueType == axevent.AXValueTypeInt {
+			kvmap[entry.Key] = int(f)
+		}
+	}
+	return kvmap
+}
+
+// replayRecording re-fires the sends of rec in the background with their original timing,
+// from the start again after the last send when loop is set. It returns false when rec is
+// already replaying.
+func (eva *EvaApplication) replayRecording(rec *Recording, loop bool) bool {
+	return eva.replays.start(eva.appCtx, &eva.playbackWg, rec.ID, func(ctx context.Context) {
+		eva.acapp.Syslog.Infof("Replay of %s started", rec.Name)
+		for eva.playRecording(ctx, rec) && loop {
+		}
+		eva.acapp.Syslog.Infof("Replay of %s ended", rec.Name)
+	})
+}
+
+// playRecording sends rec once, taking as long as the recording did, and reports whether it
+// got to the end before ctx was done. Sends of events that were deleted or are not registered
+// are skipped with a warning.
+func (eva *EvaApplication) playRecording(ctx context.Context, rec *Recording) bool {
+	start := time.Now()
+	for _, send := range rec.Sends {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(time.Until(start.Add(time.Duration(send.OffsetMs) * time.Millisecond))):
+		}
+
+		eva.mu.Lock()
+		ev := eva.findRegisteredEvent(send.EventID)
+		if ev == nil || ev.EventId == 0 {
+			eva.mu.Unlock()
+			eva.acapp.Syslog.Warnf("Replay of %s: skipping %s, event %d is not registered", rec.Name, send.Event, send.EventID)
+			continue
+		}
+		payload := ev.restoreTypes(send.Payload)
+		err := eva.sendPayload(ev, sendOptions{}, func() acapapp.KeyValueMap { return payload }, func(payload acapapp.KeyValueMap) {
+			if active, ok := payload[ev.stateKey()].(bool); ok && ev.IsStateful() {
+				ev.recordState(active, payload)
+			}
+		})
+		eva.mu.Unlock()
+		if err != nil {
+			eva.acapp.Syslog.Warnf("Replay of %s: sending %s failed: %v", rec.Name, send.Event, err)
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(time.Until(start.Add(time.Duration(rec.DurationMs) * time.Millisecond))):
+	}
+	// A recording stopped right after its start would loop without pause.
+	return rec.DurationMs > 0
+}
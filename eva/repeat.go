@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	maxRepeatCount          = 10000
+	defaultRepeatIntervalMs = 1000
+)
+
+// RepeatJob fires an event Count times, IntervalMs apart, with a fresh payload each time.
+type RepeatJob struct {
+	EventID    uint      `json:"event_id"`
+	Name       string    `json:"event"`
+	Count      int       `json:"count"`
+	IntervalMs int       `json:"interval_ms"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// parseRepeat reads the "count" and "interval_ms" query parameters of a trigger. A count of 1
+// means a single trigger.
+func parseRepeat(c fiber.Ctx) (int, time.Duration, error) {
+	count := fiber.Query[int](c, "count", 1)
+	if count < 1 || count > maxRepeatCount {
+		return 0, 0, fmt.Errorf("count must be between 1 and %d", maxRepeatCount)
+	}
+	intervalMs := fiber.Query[int](c, "interval_ms", defaultRepeatIntervalMs)
+	if intervalMs < 0 {
+		return 0, 0, fmt.Errorf("interval_ms must not be negative")
+	}
+	return count, time.Duration(intervalMs) * time.Millisecond, nil
+}
+
+// startRepeat fires the event with the given DB ID count times in the background, the first
+// time right away. Every fire looks the event up again, so edits apply and a deleted or
+// disabled event ends the job. It returns false when the event already has a repeat job.
+func (eva *EvaApplication) startRepeat(job RepeatJob, opts sendOptions) bool {
+	interval := time.Duration(job.IntervalMs) * time.Millisecond
+	return eva.repeats.start(eva.appCtx, &eva.playbackWg, job.EventID, func(ctx context.Context) {
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for i := 0; i < job.Count; i++ {
+			select {
+			case <-ctx.Done():
+				eva.acapp.Syslog.Infof("Repeated trigger of %s cancelled after %d of %d", job.Name, i, job.Count)
+				return
+			case <-timer.C:
+			}
+			if !eva.repeatFire(job.EventID, opts) {
+				eva.acapp.Syslog.Warnf("Repeated trigger of %s ended after %d of %d, the event is gone or disabled", job.Name, i, job.Count)
+				return
+			}
+			timer.Reset(interval)
+		}
+	})
+}
+
+// repeatFire triggers one repetition and reports whether the event could still be triggered.
+func (eva *EvaApplication) repeatFire(dbID uint, opts sendOptions) bool {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	registered := eva.findRegisteredEvent(dbID)
+	if registered == nil || registered.EventId == 0 || (!registered.IsEnabled() && !opts.force) {
+		return false
+	}
+	if err := eva.triggerEvent(registered, opts); err != nil {
+		eva.acapp.Syslog.Critf("Repeated trigger of %s failed: %v", registered.Name, err)
+		return true
+	}
+	registered.recordTrigger()
+	eva.scheduleFollowUps(registered, 0)
+	return true
+}
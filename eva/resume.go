@@ -0,0 +1,63 @@
+package main
+
+import "time"
+
+// RunState is the persisted state of a running simulation, used to resume it after a restart.
+type RunState struct {
+	StartedAt time.Time `json:"started_at"`
+	StopsAt   time.Time `json:"stops_at,omitzero"`
+	TimeScale float64   `json:"time_scale"`
+	NoOffset  bool      `json:"no_offset,omitempty"`
+	Seed      *int64    `json:"seed,omitempty"`
+}
+
+// AutoResumeSettings is the body of PUT /settings/auto-resume.
+type AutoResumeSettings struct {
+	AutoResume bool `json:"auto_resume"`
+}
+
+// runState returns the persisted state of run.
+func runState(run *simulationRun, timeScale float64) *RunState {
+	return &RunState{StartedAt: run.startedAt, StopsAt: run.stopsAt, TimeScale: timeScale, NoOffset: run.noOffset, Seed: run.seed}
+}
+
+// persistRun stores state as the running simulation, nil clears it. Failures are only logged:
+// they cost the resume, not the run.
+func (eva *EvaApplication) persistRun(state *RunState) {
+	if _, err := eva.updateSettings(func(s *Settings) { s.RunState = state }); err != nil {
+		eva.acapp.Syslog.Critf("Failed to persist the simulation state: %v", err)
+	}
+}
+
+// resumeRun restarts the simulation that was running when the ACAP stopped, unless auto-resume
+// is off or its duration ran out in the meantime. The ramp of the run is not repeated.
+func (eva *EvaApplication) resumeRun() {
+	settings, err := eva.loadSettings()
+	if err != nil {
+		eva.acapp.Syslog.Critf("Failed to load the simulation state: %v", err)
+		return
+	}
+	state := settings.RunState
+	if state == nil {
+		return
+	}
+	if !settings.autoResume() {
+		eva.acapp.Syslog.Infof("Simulation started at %s was running, not resumed: auto-resume is off", state.StartedAt.Format(time.RFC3339))
+		eva.persistRun(nil)
+		return
+	}
+	opts := RunOptions{TimeScale: state.TimeScale, NoOffset: state.NoOffset, Seed: state.Seed, StartedAt: state.StartedAt}
+	if !state.StopsAt.IsZero() {
+		opts.Duration = time.Until(state.StopsAt)
+		if opts.Duration <= 0 {
+			eva.acapp.Syslog.Infof("Simulation started at %s was running, not resumed: its duration ended", state.StartedAt.Format(time.RFC3339))
+			eva.persistRun(nil)
+			return
+		}
+	}
+	if err := eva.StartSimulation(opts); err != nil {
+		eva.acapp.Syslog.Critf("Failed to resume the simulation: %v", err)
+		return
+	}
+	eva.acapp.Syslog.Infof("Resumed the simulation started at %s", state.StartedAt.Format(time.RFC3339))
+}
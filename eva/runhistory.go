@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// runFlushInterval is how often the counts of a running simulation are written to its record,
+// so a crash loses at most that much.
+const runFlushInterval = 5 * time.Minute
+
+// defaultRunPageLimit is the page size of GET /simulation/runs without a limit.
+const defaultRunPageLimit = 50
+
+// SimulationRunRecord is the history entry of a simulation run, written when it starts and
+// stops and flushed periodically while it runs.
+type SimulationRunRecord struct {
+	gorm.Model
+	StartedAt   time.Time       `json:"started_at"`
+	StoppedAt   *time.Time      `json:"stopped_at"`  // Nil while running
+	StopReason  StopReason      `json:"stop_reason"` // Empty while running
+	Seed        *int64          `json:"seed"`
+	TimeScale   float64         `json:"time_scale"`
+	Resumed     bool            `json:"resumed"`      // Resumed a run that was running before a restart
+	AutoStarted bool            `json:"auto_started"` // Started with only the auto-start events
+	TotalSent   int             `json:"total_sent"`
+	Events      []RunEventCount `gorm:"serializer:json" json:"events,omitempty"`
+}
+
+// TableName stores the records as simulation_runs.
+func (SimulationRunRecord) TableName() string {
+	return "simulation_runs"
+}
+
+// RunEventCount is the number of payloads one event sent in a run.
+type RunEventCount struct {
+	EventID uint   `json:"event_id"`
+	Name    string `json:"name"`
+	Sent    int    `json:"sent"`
+}
+
+// RunSummary sums up the last simulation run once it stopped.
+type RunSummary struct {
+	RunID     uint            `json:"run_id,omitempty"` // History entry, zero when it could not be written
+	StartedAt time.Time       `json:"started_at"`
+	StoppedAt time.Time       `json:"stopped_at"`
+	Reason    StopReason      `json:"reason"`
+	TotalSent int             `json:"total_sent"`
+	Events    []RunEventCount `json:"events"`
+	LastError string          `json:"last_error,omitempty"` // Last failed send of the run
+}
+
+// sendCounts counts the sends per event of the current run. Sends come from the scheduler and
+// from requests, some holding eva.mu, so it has its own lock.
+type sendCounts struct {
+	mu      sync.Mutex
+	counts  map[uint]*RunEventCount
+	lastErr string // Last failed send, empty without one
+}
+
+// add counts a send of ev.
+func (s *sendCounts) add(ev *EvaEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = map[uint]*RunEventCount{}
+	}
+	count, ok := s.counts[ev.ID]
+	if !ok {
+		count = &RunEventCount{EventID: ev.ID}
+		s.counts[ev.ID] = count
+	}
+	count.Name = ev.Name
+	count.Sent++
+}
+
+// fail remembers a failed send of ev.
+func (s *sendCounts) fail(ev *EvaEvent, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = fmt.Sprintf("%s: %v", ev.Name, err)
+}
+
+// reset clears the counts, e.g. when the simulation starts.
+func (s *sendCounts) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts = nil
+	s.lastErr = ""
+}
+
+// lastError returns the last failed send, empty without one.
+func (s *sendCounts) lastError() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// snapshot returns the counts by event ID and their total.
+func (s *sendCounts) snapshot() ([]RunEventCount, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]RunEventCount, 0, len(s.counts))
+	total := 0
+	for _, count := range s.counts {
+		events = append(events, *count)
+		total += count.Sent
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].EventID < events[j].EventID })
+	return events, total
+}
+
+// openRunRecord resets the send counts and writes the history entry of run, which starts
+// flushing its counts. Failures are only logged: they cost the history, not the run. Caller
+// must hold eva.mu.
+func (eva *EvaApplication) openRunRecord(run *simulationRun, timeScale float64) {
+	eva.runSends.reset()
+	record := SimulationRunRecord{StartedAt: run.startedAt, Seed: run.seed, TimeScale: timeScale, Resumed: run.resumed, AutoStarted: run.autoStart}
+	if err := eva.db.Create(&record).Error; err != nil {
+		eva.acapp.Syslog.Critf("Failed to record the simulation run: %v", err)
+		return
+	}
+	run.recordID = record.ID
+	run.goRun(func(ctx context.Context) {
+		ticker := time.NewTicker(runFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				eva.flushRunRecord(run, nil)
+			}
+		}
+	})
+}
+
+// flushRunRecord writes the send counts of run to its history entry, with the stop time and
+// reason when stop is set.
+func (eva *EvaApplication) flushRunRecord(run *simulationRun, stop *SimulationRunRecord) {
+	if run.recordID == 0 {
+		return
+	}
+	events, total := eva.runSends.snapshot()
+	updates := SimulationRunRecord{Events: events, TotalSent: total}
+	if stop != nil {
+		updates.StoppedAt = stop.StoppedAt
+		updates.StopReason = stop.StopReason
+	}
+	if err := eva.db.Model(&SimulationRunRecord{Model: gorm.Model{ID: run.recordID}}).Updates(updates).Error; err != nil {
+		eva.acapp.Syslog.Critf("Failed to update the simulation run record: %v", err)
+	}
+}
+
+// closeRunRecord writes the final counts, stop time and reason of run and returns its summary.
+func (eva *EvaApplication) closeRunRecord(run *simulationRun, reason StopReason) RunSummary {
+	now := time.Now()
+	eva.flushRunRecord(run, &SimulationRunRecord{StoppedAt: &now, StopReason: reason})
+	events, total := eva.runSends.snapshot()
+	return RunSummary{RunID: run.recordID, StartedAt: run.startedAt, StoppedAt: now, Reason: reason, TotalSent: total, Events: events, LastError: eva.runSends.lastError()}
+}
+
+// closeAbandonedRuns marks the runs still open at startup as ended by an error, e.g. a crash or
+// power loss, stopped at their last flush.
+func (eva *EvaApplication) closeAbandonedRuns() {
+	var open []SimulationRunRecord
+	if err := eva.db.Where("stopped_at IS NULL").Find(&open).Error; err != nil {
+		eva.acapp.Syslog.Critf("Failed to load the open simulation runs: %v", err)
+		return
+	}
+	for _, record := range open {
+		stoppedAt := record.UpdatedAt
+		if err := eva.db.Model(&record).Updates(SimulationRunRecord{StoppedAt: &stoppedAt, StopReason: StopError}).Error; err != nil {
+			eva.acapp.Syslog.Critf("Failed to close the simulation run record %d: %v", record.ID, err)
+			continue
+		}
+		eva.acapp.Syslog.Warnf("Simulation run %d started at %s ended without a stop", record.ID, record.StartedAt.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// parseSeed reads the optional run seed of a simulation start from the "seed" query parameter
+// or JSON body, nil when none is given.
+func parseSeed(c fiber.Ctx) (*int64, error) {
+	if q := c.Query("seed"); q != "" {
+		seed, err := strconv.ParseInt(q, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed %q, expected an integer", q)
+		}
+		return &seed, nil
+	}
+	if len(c.Body()) == 0 {
+		return nil, nil
+	}
+	var body struct {
+		Seed *int64 `json:"seed"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return nil, err
+	}
+	return body.Seed, nil
+}
+
+// eventSeed derives the seed of an event from the run seed, so every event draws its own
+// sequence that does not depend on the order events fire in.
+func eventSeed(seed int64, dbID uint) int64 {
+	return int64(uint64(seed) ^ uint64(dbID)*0x9E3779B97F4A7C15)
+}
+
+// seedRun gives the event a generator derived from the run seed, used by randFor instead of
+// the global source. A nil seed removes it.
+func (e *EvaEvent) seedRun(seed *int64) {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.runRng = nil
+	if seed != nil {
+		e.state.runRng = rand.New(rand.NewSource(eventSeed(*seed, e.ID)))
+	}
+}
+
+// withRand calls f with the random source of the event, see randFor.
+func (e *EvaEvent) withRand(f func(r RandSource)) {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	f(e.randFor(&DataFields{}))
+}
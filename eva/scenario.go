@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Scenario is an ordered timeline of event triggers, e.g. "person detected, 3 seconds later
+// line crossed, 10 seconds later loitering starts", played once or in loops per run.
+type Scenario struct {
+	gorm.Model
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Steps       []ScenarioStep    `gorm:"serializer:json" json:"steps"`
+	Running     bool              `gorm:"-" json:"running"`            // Filled on read
+	Progress    *ScenarioProgress `gorm:"-" json:"progress,omitempty"` // Filled on read while running
+}
+
+// ScenarioLoops tells how often a run plays the timeline.
+type ScenarioLoops struct {
+	Loops int           // Number of plays, -1 for infinite
+	Gap   time.Duration // Pause between two plays
+}
+
+// ScenarioProgress tells where a scenario run is, or where it stopped.
+type ScenarioProgress struct {
+	Loop  int `json:"loop"`  // Current play, from 1
+	Loops int `json:"loops"` // Plays of the run, -1 for infinite
+	Step  int `json:"step"`  // Step fired last or waited for, from 1
+}
+
+// ScenarioStep triggers one event after a delay from the previous step.
+type ScenarioStep struct {
+	EventID   uint                   `json:"event_id"`
+	DelayMs   int                    `json:"delay_ms"`
+	Overrides map[string]interface{} `json:"overrides,omitempty"` // Field values by field key or name, the others are generated
+}
+
+// Validate checks the scenario name and steps. lookup returns the stored event of a step, nil
+// when it does not exist.
+func (s *Scenario) Validate(lookup func(id uint) *EvaEvent) error {
+	var errs []*FieldError
+	if strings.TrimSpace(s.Name) == "" {
+		errs = append(errs, &FieldError{Message: "scenario name must not be empty"})
+	}
+	if len(s.Steps) == 0 {
+		errs = append(errs, &FieldError{Message: "a scenario needs at least one step"})
+	}
+	for i, step := range s.Steps {
+		if step.DelayMs < 0 {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("step %d: delay_ms must not be negative", i+1)})
+		}
+		ev := lookup(step.EventID)
+		if ev == nil {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("step %d: event %d does not exist", i+1, step.EventID), Value: step.EventID})
+			continue
+		}
+		if _, err := ev.stepOverrides(step.Overrides); err != nil {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("step %d: %v", i+1, err)})
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// stepOverrides converts the overrides of a scenario step to payload values keyed by field key.
+func (e *EvaEvent) stepOverrides(overrides map[string]interface{}) (map[string]interface{}, error) {
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	values := map[string]interface{}{}
+	for key, value := range overrides {
+		field := e.fieldByKey(key)
+		if field == nil {
+			return nil, fmt.Errorf("event %s has no field %s", e.Name, key)
+		}
+		coerced, err := field.coerceValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", key, err)
+		}
+		// JSON numbers decode as float64, int fields are sent as int.
+		if f, ok := coerced.(float64); ok && field.ValueType == IntType {
+			coerced = int(f)
+		}
+		values[field.SanitizedKey()] = coerced
+	}
+	return values, nil
+}
+
+// lookupEvent returns the stored event with the given ID, nil when it does not exist.
+func (eva *EvaApplication) lookupEvent(id uint) *EvaEvent {
+	var event EvaEvent
+	if err := eva.db.First(&event, id).Error; err != nil {
+		return nil
+	}
+	return &event
+}
+
+// parseScenarioLoops reads "loops" (default 1, -1 for infinite) and "loop_gap_seconds" of a
+// scenario run from the query or JSON body.
+func parseScenarioLoops(c fiber.Ctx) (ScenarioLoops, error) {
+	loops := fiber.Query[int](c, "loops")
+	gap := fiber.Query[float64](c, "loop_gap_seconds")
+	if loops == 0 && gap == 0 && len(c.Body()) > 0 {
+		var body struct {
+			Loops          int     `json:"loops"`
+			LoopGapSeconds float64 `json:"loop_gap_seconds"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return ScenarioLoops{}, err
+		}
+		loops, gap = body.Loops, body.LoopGapSeconds
+	}
+	if loops == 0 {
+		loops = 1
+	}
+	if loops < -1 {
+		return ScenarioLoops{}, fmt.Errorf("loops must be positive or -1 for infinite")
+	}
+	if gap < 0 {
+		return ScenarioLoops{}, fmt.Errorf("loop_gap_seconds must not be negative")
+	}
+	return ScenarioLoops{Loops: loops, Gap: time.Duration(gap * float64(time.Second))}, nil
+}
+
+// runScenario plays the steps of s in the background, as often as loops says. It returns false
+// when s is already playing. The run is cancelled by stopScenario or on shutdown.
+func (eva *EvaApplication) runScenario(s *Scenario, loops ScenarioLoops) bool {
+	return eva.scenarioRuns.start(eva.appCtx, &eva.playbackWg, s.ID, func(ctx context.Context) {
+		eva.playScenario(ctx, s, loops)
+	})
+}
+
+// playScenario plays the timeline of s loops times, or until ctx is done. Counters and other
+// generator state carry on from one play to the next.
+func (eva *EvaApplication) playScenario(ctx context.Context, s *Scenario, loops ScenarioLoops) {
+	eva.acapp.Syslog.Infof("Scenario %s started", s.Name)
+	for loop := 1; loops.Loops < 0 || loop <= loops.Loops; loop++ {
+		if loop > 1 {
+			select {
+			case <-ctx.Done():
+				eva.acapp.Syslog.Infof("Scenario %s stopped after loop %d", s.Name, loop-1)
+				return
+			case <-time.After(loops.Gap):
+			}
+		}
+		if !eva.playScenarioSteps(ctx, s, ScenarioProgress{Loop: loop, Loops: loops.Loops}) {
+			return
+		}
+	}
+	eva.acapp.Syslog.Infof("Scenario %s finished", s.Name)
+}
+
+// playScenarioSteps sends the steps of s in order and reports whether it got past the last step
+// before ctx was done. Steps whose event was deleted or is not registered are skipped with a
+// warning.
+func (eva *EvaApplication) playScenarioSteps(ctx context.Context, s *Scenario, progress ScenarioProgress) bool {
+	for i, step := range s.Steps {
+		progress.Step = i + 1
+		eva.mu.Lock()
+		eva.setScenarioProgress(s.ID, progress)
+		eva.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			eva.acapp.Syslog.Infof("Scenario %s stopped in loop %d at step %d", s.Name, progress.Loop, progress.Step)
+			return false
+		case <-time.After(time.Duration(step.DelayMs) * time.Millisecond):
+		}
+
+		eva.mu.Lock()
+		ev := eva.findRegisteredEvent(step.EventID)
+		if ev == nil || ev.EventId == 0 {
+			eva.mu.Unlock()
+			eva.acapp.Syslog.Warnf("Scenario %s: skipping step %d, event %d is not registered", s.Name, i+1, step.EventID)
+			continue
+		}
+		overrides, err := ev.stepOverrides(step.Overrides)
+		if err == nil {
+			err = eva.triggerEvent(ev, sendOptions{sources: overrides})
+		}
+		eva.mu.Unlock()
+		if err != nil {
+			eva.acapp.Syslog.Warnf("Scenario %s: step %d failed: %v", s.Name, i+1, err)
+		}
+	}
+	return true
+}
+
+// setScenarioProgress records where the run of the scenario with the given ID is. Caller must
+// hold eva.mu.
+func (eva *EvaApplication) setScenarioProgress(id uint, progress ScenarioProgress) {
+	if eva.scenarioProgress == nil {
+		eva.scenarioProgress = map[uint]ScenarioProgress{}
+	}
+	eva.scenarioProgress[id] = progress
+}
+
+// lastScenarioProgress returns where the last run of the scenario with the given ID is or
+// stopped, nil when it never played.
+func (eva *EvaApplication) lastScenarioProgress(id uint) *ScenarioProgress {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	progress, ok := eva.scenarioProgress[id]
+	if !ok {
+		return nil
+	}
+	return &progress
+}
+
+// fillScenarioStatus sets Running and, while it plays, Progress of s.
+func (eva *EvaApplication) fillScenarioStatus(s *Scenario) {
+	s.Running = eva.scenarioRunning(s.ID)
+	if s.Running {
+		s.Progress = eva.lastScenarioProgress(s.ID)
+	}
+}
+
+// stopScenario cancels the run of the scenario with the given ID and waits for it. It returns
+// false when the scenario was not playing.
+func (eva *EvaApplication) stopScenario(id uint) bool {
+	return eva.scenarioRuns.stop(id)
+}
+
+// scenarioRunning reports whether the scenario with the given ID is playing.
+func (eva *EvaApplication) scenarioRunning(id uint) bool {
+	return eva.scenarioRuns.running(id)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// importRow is one trigger of an imported timeline.
+type importRow struct {
+	Line            int                    `json:"-"`
+	TimestampOffset float64                `json:"timestamp_offset"` // Seconds since the start of the timeline
+	EventName       string                 `json:"event_name"`
+	Values          map[string]interface{} `json:"values"`
+}
+
+// ImportProblem reports a row of an import that could not be mapped.
+type ImportProblem struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// parseTimeline reads a trigger timeline from a JSON array of rows or from CSV lines of
+// "timestamp_offset,event_name,key=value,...", with an optional header line. Lines are numbered
+// from 1, for JSON by array index.
+func parseTimeline(body []byte) ([]importRow, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("timeline is empty")
+	}
+	if trimmed[0] == '[' {
+		var rows []importRow
+		if err := json.Unmarshal(trimmed, &rows); err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			rows[i].Line = i + 1
+		}
+		return rows, nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(trimmed))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected timestamp_offset and event_name", line)
+		}
+		offset, err := strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+		if err != nil {
+			if line == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("line %d: invalid timestamp_offset %q", line, record[0])
+		}
+		row := importRow{Line: line, TimestampOffset: offset, EventName: strings.TrimSpace(record[1]), Values: map[string]interface{}{}}
+		for _, pair := range record[2:] {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key=value, got %q", line, pair)
+			}
+			row.Values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// timelineSteps maps rows to scenario steps of the events with the same sanitized name. Rows
+// with unknown events or keys, or an offset before the previous row, are left out and reported.
+func timelineSteps(rows []importRow, events []EvaEvent) ([]ScenarioStep, []ImportProblem) {
+	byName := map[string]*EvaEvent{}
+	for i := range events {
+		byName[sanitizeEventName(events[i].Name)] = &events[i]
+	}
+	steps := []ScenarioStep{}
+	problems := []ImportProblem{}
+	previous := 0.0
+	for _, row := range rows {
+		ev := byName[sanitizeEventName(row.EventName)]
+		if ev == nil {
+			problems = append(problems, ImportProblem{Line: row.Line, Message: fmt.Sprintf("unknown event %q", row.EventName)})
+			continue
+		}
+		if row.TimestampOffset < previous {
+			problems = append(problems, ImportProblem{Line: row.Line, Message: "timestamp_offset is before the previous row"})
+			continue
+		}
+		if _, err := ev.stepOverrides(row.Values); err != nil {
+			problems = append(problems, ImportProblem{Line: row.Line, Message: err.Error()})
+			continue
+		}
+		steps = append(steps, ScenarioStep{
+			EventID:   ev.ID,
+			DelayMs:   int(math.Round((row.TimestampOffset - previous) * 1000)),
+			Overrides: row.Values,
+		})
+		previous = row.TimestampOffset
+	}
+	return steps, problems
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/gofiber/fiber/v3"
+)
+
+// usesRandomInterval reports whether each gap is drawn between IntervalMinSeconds and IntervalMaxSeconds.
+func (e *EvaEvent) usesRandomInterval() bool {
+	return e.UseRandomInterval != nil && *e.UseRandomInterval && e.IntervalMinSeconds > 0 && e.IntervalMaxSeconds >= e.IntervalMinSeconds
+}
+
+// minIntervalMs is the shortest accepted IntervalMs.
+const minIntervalMs = 10
+
+// baseInterval returns the fixed interval: IntervalMs when set, otherwise IntervalSeconds.
+func (e *EvaEvent) baseInterval() time.Duration {
+	if e.IntervalMs > 0 {
+		return time.Duration(e.IntervalMs) * time.Millisecond
+	}
+	return time.Duration(e.IntervalSeconds) * time.Second
+}
+
+// hasInterval reports whether the simulation fires the event on an interval.
+func (e *EvaEvent) hasInterval() bool {
+	return e.usesRandomInterval() || e.baseInterval() > 0
+}
+
+// nextInterval returns the gap before the next simulated fire: a random gap between the min and
+// max interval, or the fixed interval varied by up to JitterPercent in either direction. The
+// interval of the active time band replaces both.
+func (e *EvaEvent) nextInterval() time.Duration {
+	var gap time.Duration
+	base := e.baseInterval()
+	if band := e.activeBand(time.Now()); band != nil && band.IntervalMs > 0 {
+		base = time.Duration(band.IntervalMs) * time.Millisecond
+	} else if e.usesRandomInterval() {
+		e.withRand(func(r RandSource) {
+			gap = time.Duration(RandomIntInRange(r, e.IntervalMinSeconds, e.IntervalMaxSeconds)) * time.Second
+		})
+		return gap
+	}
+	if e.JitterPercent <= 0 {
+		return base
+	}
+	spread := float64(base) * float64(e.JitterPercent) / 100
+	e.withRand(func(r RandSource) { gap = base + time.Duration(RandomFloatInRange(r, -spread, spread)) })
+	return gap
+}
+
+// validateSchedule checks the interval settings of the event.
+func (e *EvaEvent) validateSchedule() []*FieldError {
+	var errs []*FieldError
+	if e.UseRandomInterval != nil && *e.UseRandomInterval {
+		if e.IntervalMinSeconds <= 0 || e.IntervalMaxSeconds <= 0 {
+			errs = append(errs, &FieldError{Message: "a random interval requires interval_min_seconds and interval_max_seconds greater than 0"})
+		} else if e.IntervalMinSeconds > e.IntervalMaxSeconds {
+			errs = append(errs, &FieldError{Message: "interval_min_seconds must not exceed interval_max_seconds"})
+		}
+	} else if e.IntervalSeconds < 0 {
+		errs = append(errs, &FieldError{Message: "interval_seconds must not be negative"})
+	}
+	if e.IntervalMs != 0 && e.IntervalMs < minIntervalMs {
+		errs = append(errs, &FieldError{Message: fmt.Sprintf("interval_ms must be at least %d", minIntervalMs)})
+	}
+	if e.CronSpec != "" {
+		if schedule, err := parseCron(e.CronSpec); err != nil {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("cron_spec: %v", err)})
+		} else if schedule.next(time.Now()).IsZero() {
+			errs = append(errs, &FieldError{Message: "cron_spec never matches"})
+		}
+		if e.hasDutyCycle() {
+			errs = append(errs, &FieldError{Message: "cron_spec cannot be combined with a duty cycle"})
+		}
+	}
+	if e.MaxTriggers < 0 {
+		errs = append(errs, &FieldError{Message: "max_triggers must not be negative"})
+	}
+	if e.JitterPercent < 0 || e.JitterPercent > 100 {
+		errs = append(errs, &FieldError{Message: "jitter_percent must be between 0 and 100"})
+	}
+	if e.alignsToClock() && (e.usesRandomInterval() || e.JitterPercent > 0) {
+		errs = append(errs, &FieldError{Message: "align_to_clock requires a fixed interval without jitter_percent"})
+	}
+	return errs
+}
+
+// Bounds of the simulation time scale, values outside are clamped.
+const (
+	minTimeScale = 0.1
+	maxTimeScale = 100
+)
+
+// parseTimeScale reads the optional time scale of a simulation start from the "time_scale" query
+// parameter or JSON body. Zero means real time; the result is clamped to minTimeScale..maxTimeScale.
+func parseTimeScale(c fiber.Ctx) (float64, error) {
+	scale := fiber.Query[float64](c, "time_scale")
+	if scale == 0 && len(c.Body()) > 0 {
+		var body struct {
+			TimeScale float64 `json:"time_scale"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return 0, err
+		}
+		scale = body.TimeScale
+	}
+	if scale < 0 {
+		return 0, fmt.Errorf("time_scale must not be negative")
+	}
+	if scale == 0 {
+		return 1, nil
+	}
+	return math.Min(math.Max(scale, minTimeScale), maxTimeScale), nil
+}
+
+// TimeScale returns the time scale of the running simulation, 1 outside of a run.
+func (eva *EvaApplication) TimeScale() float64 {
+	scale := math.Float64frombits(eva.timeScale.Load())
+	if scale <= 0 {
+		return 1
+	}
+	return scale
+}
+
+// scaled divides d by the time scale of the running simulation. It never shortens a positive
+// duration below a millisecond, so fast-forwarded timers do not spin.
+func (eva *EvaApplication) scaled(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return max(time.Duration(float64(d)/eva.TimeScale()), time.Millisecond)
+}
+
+// intervalJob fires ev on its interval. Fires are scheduled from the previous due time, not from
+// when the send completed, so send latency does not add up and the long-run rate matches the
+// interval. Due times missed while the event was busy, e.g. active for its
+// ActiveDurationSeconds or in a send slower than the interval, are skipped. Unless the run
+// turned the phase offset off, the first fire comes after a random fraction of the interval, so
+// events with the same interval do not fire in lockstep. The offset counts from the end of the
+// warm-up.
+func (eva *EvaApplication) intervalJob(run *simulationRun, ev *EvaEvent) *simJob {
+	gap := func() time.Duration { return eva.scaled(run.ramped(ev.nextInterval())) }
+	if ev.alignsToClock() {
+		return eva.alignedJob(run, ev, gap)
+	}
+	first := gap()
+	if !run.noOffset {
+		ev.withRand(func(r RandSource) { first = time.Duration(r.Float64() * float64(first)) })
+	}
+	return &simJob{
+		eventID: ev.ID,
+		at:      run.firstFireFrom().Add(first),
+		fire: func(at time.Time) (time.Time, bool) {
+			done, busyUntil := eva.simulateFire(run, ev)
+			if done {
+				return time.Time{}, false
+			}
+			next := at.Add(gap())
+			for busyUntil = later(busyUntil, run.sched.now()); next.Before(busyUntil); {
+				next = next.Add(gap())
+			}
+			return next, true
+		},
+	}
+}
+
+// alignsToClock reports whether the interval fires on wall-clock multiples of the interval.
+func (e *EvaEvent) alignsToClock() bool {
+	return e.AlignToClock != nil && *e.AlignToClock
+}
+
+// alignedJob fires ev on the wall-clock multiples of its interval, e.g. at :00, :15, :30 and :45
+// for 15 seconds. Every due time is computed from the clock, so long runs do not drift, and
+// multiples passed while the event was busy are skipped.
+func (eva *EvaApplication) alignedJob(run *simulationRun, ev *EvaEvent, gap func() time.Duration) *simJob {
+	return &simJob{
+		eventID: ev.ID,
+		at:      nextMultiple(run.firstFireFrom(), gap()),
+		fire: func(time.Time) (time.Time, bool) {
+			done, busyUntil := eva.simulateFire(run, ev)
+			if done {
+				return time.Time{}, false
+			}
+			return nextMultiple(later(busyUntil, run.sched.now()), gap()), true
+		},
+	}
+}
+
+// nextMultiple returns the first multiple of d after t, counted from the zero time, so
+// intervals dividing a minute land on the same seconds of every minute.
+func nextMultiple(t time.Time, d time.Duration) time.Time {
+	return t.Truncate(d).Add(d)
+}
+
+// later returns the later of a and b.
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// simulateFire fires ev for the simulation and reports whether it reached MaxTriggers, and
+// until when the event is busy with a scheduled fall. While the simulation is paused nothing
+// is sent or counted, during quiet hours the held send is counted, as is a send skipped by an
+// unmet FireCondition. FireProbability skips ticks at random, those are counted apart from the
+// fires.
+func (eva *EvaApplication) simulateFire(run *simulationRun, ev *EvaEvent) (bool, time.Time) {
+	if eva.isPaused() {
+		return false, time.Time{}
+	}
+	if eva.isQuiet(ev, time.Now()) {
+		ev.recordQuiet()
+		return false, time.Time{}
+	}
+	if !eva.fireConditionMet(ev) {
+		ev.recordUnmet()
+		return false, time.Time{}
+	}
+	if !ev.rollFire() {
+		ev.dropTick()
+		return false, time.Time{}
+	}
+	busyUntil := eva.fireSimulated(run, ev)
+	eva.mu.Lock()
+	eva.scheduleFollowUps(ev, 0)
+	eva.mu.Unlock()
+	return ev.recordFire(), busyUntil
+}
+
+// isPaused reports whether the running simulation is paused.
+func (eva *EvaApplication) isPaused() bool {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	return eva.simPaused
+}
+
+// EventRunStatus reports the simulated fires of an event in the current run.
+type EventRunStatus struct {
+	EventID     uint                `json:"event_id"`
+	Name        string              `json:"event"`
+	Fired       int                 `json:"fired"`
+	Completed   bool                `json:"completed"`              // MaxTriggers was reached
+	Running     bool                `json:"running"`                // It is scheduled in the running simulation
+	Quiet       int                 `json:"quiet_skipped"`          // Sends held by quiet hours
+	Dropped     int                 `json:"probability_skipped"`    // Ticks skipped by FireProbability
+	Unmet       int                 `json:"condition_skipped"`      // Sends skipped by FireCondition
+	Triggered   int                 `json:"manual_triggers"`        // Manual triggers since the simulation last started
+	LastFire    time.Time           `json:"last_fire,omitzero"`     // Last send of any kind
+	LastPayload acapapp.KeyValueMap `json:"last_payload,omitempty"` // Payload of the last send
+	NextFire    time.Time           `json:"next_fire,omitzero"`     // Next due time in the running simulation
+	Band        string              `json:"band,omitempty"`         // Active time band
+}
+
+// recordFire counts a simulated fire and reports whether the event reached MaxTriggers.
+func (e *EvaEvent) recordFire() bool {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.fired++
+	return e.MaxTriggers > 0 && e.state.fired >= e.MaxTriggers
+}
+
+// recordTrigger counts a manual trigger.
+func (e *EvaEvent) recordTrigger() {
+	e.state.mu.Lock()
+	e.state.triggers++
+	e.state.mu.Unlock()
+}
+
+// resetRun restarts the fire, skip and trigger counts of the event for a new run.
+func (e *EvaEvent) resetRun() {
+	if e.state == nil {
+		return
+	}
+	e.state.mu.Lock()
+	e.state.fired = 0
+	e.state.quiet = 0
+	e.state.dropped = 0
+	e.state.triggers = 0
+	e.state.unmet = 0
+	e.state.mu.Unlock()
+}
+
+// RunStatus returns the simulated fires of the event in the current run.
+func (e *EvaEvent) RunStatus() EventRunStatus {
+	status := EventRunStatus{EventID: e.ID, Name: e.Name}
+	if band := e.activeBand(time.Now()); band != nil {
+		status.Band = band.label()
+	}
+	if e.state == nil {
+		return status
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	status.Fired = e.state.fired
+	status.Quiet = e.state.quiet
+	status.Dropped = e.state.dropped
+	status.Unmet = e.state.unmet
+	status.Triggered = e.state.triggers
+	status.LastFire = e.state.lastSentAt
+	status.LastPayload = e.state.lastPayload
+	status.Completed = e.MaxTriggers > 0 && e.state.fired >= e.MaxTriggers
+	return status
+}
+
+// StopReason tells why a simulation run stopped.
+type StopReason string
+
+const (
+	StopManual   StopReason = "manual"
+	StopTimeout  StopReason = "timeout"
+	StopShutdown StopReason = "shutdown"
+	StopError    StopReason = "error" // The run ended without a stop, e.g. by a crash
+)
+
+// RunOptions are the settings of a single simulation run.
+type RunOptions struct {
+	Duration  time.Duration // Stops the run automatically when positive
+	TimeScale float64       // Fast-forwards the run, see parseTimeScale
+	Ramp      ramp          // Raises the interval event rates at the start of the run
+	NoOffset  bool          // Interval events fire their first time a full interval after the start
+	Seed      *int64        // Seeds all randomness of the run, nil for the global source
+	StartedAt time.Time     // Original start of a resumed run, zero for a new run
+	Warmup    time.Duration // Delays the first fire of every event
+}
+
+// parseRunOptions reads the run settings of a simulation start request.
+func parseRunOptions(c fiber.Ctx) (RunOptions, error) {
+	var opts RunOptions
+	var err error
+	if opts.Duration, err = parseSimulationDuration(c); err != nil {
+		return opts, err
+	}
+	if opts.TimeScale, err = parseTimeScale(c); err != nil {
+		return opts, err
+	}
+	if opts.Ramp, err = parseRamp(c); err != nil {
+		return opts, err
+	}
+	if opts.NoOffset, err = parseNoOffset(c); err != nil {
+		return opts, err
+	}
+	if opts.Seed, err = parseSeed(c); err != nil {
+		return opts, err
+	}
+	if opts.Warmup, err = parseWarmup(c); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseNoOffset reads whether a simulation start turns the random phase offset off, from the
+// "phase_offset" query parameter or JSON body. The offset is on by default.
+func parseNoOffset(c fiber.Ctx) (bool, error) {
+	if q := c.Query("phase_offset"); q != "" {
+		on, err := strconv.ParseBool(q)
+		if err != nil {
+			return false, fmt.Errorf("invalid phase_offset %q, expected true or false", q)
+		}
+		return !on, nil
+	}
+	if len(c.Body()) == 0 {
+		return false, nil
+	}
+	var body struct {
+		PhaseOffset *bool `json:"phase_offset"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return false, err
+	}
+	return body.PhaseOffset != nil && !*body.PhaseOffset, nil
+}
+
+// parseSimulationDuration reads the optional run duration of a simulation start from the
+// "duration_seconds" query parameter or JSON body. Zero means run until stopped.
+func parseSimulationDuration(c fiber.Ctx) (time.Duration, error) {
+	seconds := fiber.Query[float64](c, "duration_seconds")
+	if seconds == 0 && len(c.Body()) > 0 {
+		var body struct {
+			DurationSeconds float64 `json:"duration_seconds"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return 0, err
+		}
+		seconds = body.DurationSeconds
+	}
+	if seconds < 0 {
+		return 0, fmt.Errorf("duration_seconds must not be negative")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// autoStop stops run after d, unless it was stopped before.
+func (eva *EvaApplication) autoStop(run *simulationRun, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-run.ctx.Done():
+	case <-timer.C:
+		if run.ctx.Err() != nil {
+			return
+		}
+		eva.acapp.Syslog.Infof("Simulation stopped after %s", d)
+		eva.stopRun(run, StopTimeout, defaultDrainTimeout)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// simJob is a queued simulation fire of an event.
+type simJob struct {
+	eventID uint
+	at      time.Time
+	fire    func(at time.Time) (time.Time, bool) // Fires the job due at at, returns the next due time or false when done
+	stop    func()                               // Called once the job leaves the scheduler, may be nil
+	index   int                                  // Position in the heap
+	removed bool
+}
+
+// jobHeap is a min-heap of jobs ordered by due time.
+type jobHeap []*simJob
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x any) {
+	job := x.(*simJob)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	job.index = -1
+	*h = old[:len(old)-1]
+	return job
+}
+
+// scheduler fires the simulation jobs of all events from a single goroutine that sleeps until
+// the earliest due job, fires it and queues it again at its next due time.
+type scheduler struct {
+	mu     sync.Mutex
+	jobs   jobHeap
+	events map[uint]*simJob // Main job of each scheduled event
+	wake   chan struct{}
+	fireMu sync.Mutex       // Held while a job fires
+	now    func() time.Time // Clock of the due times, replaced in tests to drive simulated time
+
+	draining  bool          // The main jobs are gone, the remaining jobs fire until none are left
+	idle      chan struct{} // Closed once a drain fired all remaining jobs
+	drained   int           // Fires completed during the drain
+	abandoned int           // Jobs still queued when the scheduler stopped during a drain
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{events: map[uint]*simJob{}, wake: make(chan struct{}, 1), idle: make(chan struct{}), now: time.Now}
+}
+
+// add queues job. The main job of an event marks it as scheduled; other jobs, e.g. the fall of
+// an active duration, are removed with it.
+func (s *scheduler) add(job *simJob, main bool) {
+	s.mu.Lock()
+	heap.Push(&s.jobs, job)
+	if main {
+		s.events[job.eventID] = job
+	}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// remove drops all jobs of the event and waits for a fire in progress. It returns false when
+// the event was not scheduled.
+func (s *scheduler) remove(eventID uint) bool {
+	s.mu.Lock()
+	var queued []*simJob
+	for _, job := range s.jobs {
+		if job.eventID == eventID {
+			queued = append(queued, job)
+		}
+	}
+	for _, job := range queued {
+		heap.Remove(&s.jobs, job.index)
+		job.removed = true
+	}
+	main, ok := s.events[eventID]
+	if ok {
+		s.finish(main)
+	}
+	s.mu.Unlock()
+
+	s.fireMu.Lock()
+	s.fireMu.Unlock()
+	return ok
+}
+
+// scheduled reports whether the event has a main job.
+func (s *scheduler) scheduled(eventID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.events[eventID]
+	return ok
+}
+
+// nextFire returns when the main job of the event is due next.
+func (s *scheduler) nextFire(eventID uint) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.events[eventID]
+	if !ok {
+		return time.Time{}, false
+	}
+	return job.at, true
+}
+
+// finish takes job out of the scheduler for good. Caller must hold s.mu.
+func (s *scheduler) finish(job *simJob) {
+	job.removed = true
+	if s.events[job.eventID] == job {
+		delete(s.events, job.eventID)
+	}
+	if job.stop != nil {
+		job.stop()
+	}
+}
+
+// run fires the due jobs until ctx is done, then drops all jobs.
+func (s *scheduler) run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	defer s.clear()
+	for {
+		s.mu.Lock()
+		if s.draining && len(s.jobs) == 0 {
+			close(s.idle)
+			s.mu.Unlock()
+			return
+		}
+		wait := time.Duration(-1)
+		if len(s.jobs) > 0 {
+			wait = max(s.jobs[0].at.Sub(s.now()), 0)
+		}
+		s.mu.Unlock()
+
+		if wait == 0 {
+			s.fireDue()
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		timerC := (<-chan time.Time)(nil)
+		if wait > 0 {
+			timer.Reset(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timerC:
+		}
+	}
+}
+
+// fireDue fires the earliest job if it is due and queues it again.
+func (s *scheduler) fireDue() {
+	s.fireMu.Lock()
+	defer s.fireMu.Unlock()
+	s.mu.Lock()
+	if len(s.jobs) == 0 || s.jobs[0].at.After(s.now()) {
+		s.mu.Unlock()
+		return
+	}
+	job := heap.Pop(&s.jobs).(*simJob)
+	s.mu.Unlock()
+
+	next, ok := job.fire(job.at)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		s.drained++
+	}
+	if job.removed {
+		return
+	}
+	if !ok {
+		s.finish(job)
+		return
+	}
+	job.at = next
+	heap.Push(&s.jobs, job)
+}
+
+// drain takes the main jobs of all events out, so no new fires are armed, while the remaining
+// jobs, e.g. pending falls, still fire. idle is closed once they did.
+func (s *scheduler) drain() {
+	s.mu.Lock()
+	s.draining = true
+	for _, job := range s.events {
+		// A job that is firing right now is not in the heap and is not queued again.
+		if job.index >= 0 && !job.removed {
+			heap.Remove(&s.jobs, job.index)
+		}
+		s.finish(job)
+	}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drainResult returns the fires completed during the drain and the jobs it dropped.
+func (s *scheduler) drainResult() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drained, s.abandoned
+}
+
+// clear drops all jobs.
+func (s *scheduler) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		s.abandoned = len(s.jobs)
+	}
+	for _, job := range s.jobs {
+		job.removed = true
+	}
+	s.jobs = nil
+	for _, job := range s.events {
+		s.finish(job)
+	}
+}
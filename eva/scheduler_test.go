@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axevent"
+	"github.com/Cacsjep/goxis/pkg/axsyslog"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// testClock is the simulated clock the tests drive the scheduler with.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// advance moves the clock forward by d.
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// advanceTo moves the clock forward to t, it never goes back.
+func (c *testClock) advanceTo(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// testSend is a send made through the stubbed platform.
+type testSend struct {
+	eventID int
+	at      time.Time
+}
+
+// testPlatform stands in for the camera: it records the sends of the application, stamped with
+// clock when set, and lets each send take latency on that clock.
+type testPlatform struct {
+	mu      sync.Mutex
+	clock   *testClock
+	latency time.Duration
+	sends   []testSend
+}
+
+func (p *testPlatform) send(eventID int, build func() (*axevent.AXEvent, error)) error {
+	if _, err := build(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	at := time.Now()
+	if p.clock != nil {
+		at = p.clock.Now()
+		p.clock.advance(p.latency)
+	}
+	p.sends = append(p.sends, testSend{eventID: eventID, at: at})
+	return nil
+}
+
+// sendsOf returns the send times of the event with the given platform ID.
+func (p *testPlatform) sendsOf(eventID int) []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var times []time.Time
+	for _, send := range p.sends {
+		if send.eventID == eventID {
+			times = append(times, send.at)
+		}
+	}
+	return times
+}
+
+// newTestEva returns an application with a temporary database whose sends go to the returned
+// platform stub instead of the camera.
+func newTestEva(t *testing.T) (*EvaApplication, *testPlatform) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "eva.sqlite")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&EvaEvent{}, &FieldTemplate{}, &Scenario{}, &Recording{}, &Settings{}, &SimulationRunRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	platform := &testPlatform{}
+	eva := &EvaApplication{db: db, sendEvent: platform.send}
+	eva.acapp.Syslog = axsyslog.NewSyslog("eva-test", axsyslog.LOG_PID, axsyslog.LOG_CRIT)
+	return eva, platform
+}
+
+// testIntervalEvent returns a registered stateless event firing every interval, with the
+// platform ID id.
+func testIntervalEvent(eva *EvaApplication, id uint, interval time.Duration) *EvaEvent {
+	ev := &EvaEvent{
+		Name:        fmt.Sprintf("Test Event %d", id),
+		Enabled:     boolPtr(true),
+		UseInterval: boolPtr(true),
+		IntervalMs:  int(interval / time.Millisecond),
+		Stateless:   boolPtr(true),
+	}
+	ev.ID = id
+	ev.SetupPlatformEvent(eva)
+	ev.EventId = int(id)
+	return ev
+}
+
+// simulate fires the due jobs of run in order until clock reaches end, like the scheduler
+// goroutine but without waiting for real time.
+func simulate(run *simulationRun, clock *testClock, end time.Time) {
+	for {
+		run.sched.mu.Lock()
+		if len(run.sched.jobs) == 0 || run.sched.jobs[0].at.After(end) {
+			run.sched.mu.Unlock()
+			break
+		}
+		at := run.sched.jobs[0].at
+		run.sched.mu.Unlock()
+		clock.advanceTo(at)
+		run.sched.fireDue()
+	}
+	clock.advanceTo(end)
+}
+
+// newTestRun returns a run whose scheduler is driven by clock.
+func newTestRun(clock *testClock, opts RunOptions) *simulationRun {
+	run := newSimulationRun(opts)
+	run.sched.now = clock.Now
+	return run
+}
+
+func TestIntervalJobFireCount(t *testing.T) {
+	eva, platform := newTestEva(t)
+	clock := newTestClock()
+	platform.clock = clock
+	run := newTestRun(clock, RunOptions{NoOffset: true})
+
+	tests := []struct {
+		interval time.Duration
+		want     int
+	}{
+		{5 * time.Second, 720},
+		{time.Second, 3600},
+		{250 * time.Millisecond, 14400},
+		{7 * time.Second, 514},
+	}
+	for i, tt := range tests {
+		run.sched.add(eva.intervalJob(run, testIntervalEvent(eva, uint(i+1), tt.interval)), true)
+	}
+	simulate(run, clock, clock.Now().Add(time.Hour))
+
+	for i, tt := range tests {
+		if got := len(platform.sendsOf(i + 1)); got != tt.want {
+			t.Errorf("interval %s: got %d fires in an hour, want %d", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestIntervalJobIndependentPhases(t *testing.T) {
+	eva, platform := newTestEva(t)
+	clock := newTestClock()
+	platform.clock = clock
+	run := newTestRun(clock, RunOptions{NoOffset: true})
+	start := clock.Now()
+
+	run.sched.add(eva.intervalJob(run, testIntervalEvent(eva, 1, 2*time.Second)), true)
+	simulate(run, clock, start.Add(3*time.Second))
+	// An event joining later runs on its own phase and does not move the first one.
+	run.sched.add(eva.intervalJob(run, testIntervalEvent(eva, 2, 2*time.Second)), true)
+	simulate(run, clock, start.Add(time.Minute))
+
+	for id, first := range map[int]time.Time{1: start, 2: start.Add(3 * time.Second)} {
+		for n, at := range platform.sendsOf(id) {
+			if want := first.Add(time.Duration(n+1) * 2 * time.Second); !at.Equal(want) {
+				t.Fatalf("event %d: fire %d at %s, want %s", id, n+1, at.Sub(start), want.Sub(start))
+			}
+		}
+	}
+	if got := len(platform.sendsOf(1)); got != 30 {
+		t.Errorf("event 1: got %d fires, want 30", got)
+	}
+	if got := len(platform.sendsOf(2)); got != 28 {
+		t.Errorf("event 2: got %d fires, want 28", got)
+	}
+}
+
+func TestIntervalJobPhaseOffset(t *testing.T) {
+	for _, noOffset := range []bool{false, true} {
+		eva, platform := newTestEva(t)
+		clock := newTestClock()
+		platform.clock = clock
+		run := newTestRun(clock, RunOptions{NoOffset: noOffset})
+		start := clock.Now()
+
+		for id := uint(1); id <= 2; id++ {
+			run.sched.add(eva.intervalJob(run, testIntervalEvent(eva, id, 5*time.Second)), true)
+		}
+		simulate(run, clock, start.Add(time.Minute))
+
+		first, second := platform.sendsOf(1), platform.sendsOf(2)
+		if len(first) == 0 || len(second) == 0 {
+			t.Fatalf("noOffset=%v: no fires", noOffset)
+		}
+		shared := 0
+		for _, a := range first {
+			for _, b := range second {
+				if a.Equal(b) {
+					shared++
+				}
+			}
+		}
+		if noOffset && shared != len(first) {
+			t.Errorf("without phase offset: %d of %d fire timestamps shared, want all", shared, len(first))
+		}
+		if !noOffset && shared > 0 {
+			t.Errorf("with phase offset: %d fire timestamps shared, want none", shared)
+		}
+		if !noOffset && (first[0].Sub(start) > 5*time.Second || second[0].Sub(start) > 5*time.Second) {
+			t.Errorf("with phase offset: first fires after %s and %s, want within an interval", first[0].Sub(start), second[0].Sub(start))
+		}
+	}
+}
+
+func TestIntervalJobSlowSendsDoNotDrift(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		latency  time.Duration
+		want     int
+	}{
+		{5 * time.Second, 1500 * time.Millisecond, 720},
+		{time.Second, 900 * time.Millisecond, 3600},
+		// Sends slower than the interval skip the due times they missed.
+		{time.Second, 2500 * time.Millisecond, 1200},
+	}
+	for _, tt := range tests {
+		eva, platform := newTestEva(t)
+		clock := newTestClock()
+		platform.clock = clock
+		platform.latency = tt.latency
+		run := newTestRun(clock, RunOptions{NoOffset: true})
+
+		run.sched.add(eva.intervalJob(run, testIntervalEvent(eva, 1, tt.interval)), true)
+		simulate(run, clock, clock.Now().Add(time.Hour))
+
+		if got := len(platform.sendsOf(1)); got < tt.want-1 || got > tt.want+1 {
+			t.Errorf("interval %s with %s sends: got %d fires in an hour, want %d±1", tt.interval, tt.latency, got, tt.want)
+		}
+	}
+}
+
+func TestDutyCycleJobSlowSendsDoNotDrift(t *testing.T) {
+	// Sends slower than a phase are caught up as long as the cycle keeps up on average.
+	for _, latency := range []time.Duration{500 * time.Millisecond, 2500 * time.Millisecond} {
+		eva, platform := newTestEva(t)
+		clock := newTestClock()
+		platform.clock = clock
+		platform.latency = latency
+		run := newTestRun(clock, RunOptions{})
+
+		ev := testIntervalEvent(eva, 1, 0)
+		ev.Stateless = boolPtr(false)
+		ev.ActiveSeconds = 3
+		ev.InactiveSeconds = 2
+		ev.DataFields = []DataFields{{Name: "Active", ValueType: BoolType}}
+		ev.SetupPlatformEvent(eva)
+		run.sched.add(eva.dutyCycleJob(run, ev), true)
+		simulate(run, clock, clock.Now().Add(time.Hour))
+
+		// A rise and a fall every 5 seconds.
+		if got, want := len(platform.sendsOf(1)), 1440; got < want-1 || got > want+1 {
+			t.Errorf("%s sends: got %d sends in an hour, want %d±1", latency, got, want)
+		}
+	}
+}
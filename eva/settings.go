@@ -0,0 +1,48 @@
+package main
+
+import "gorm.io/gorm"
+
+// Settings holds the global settings, stored as a single row.
+type Settings struct {
+	gorm.Model
+	QuietHours         []QuietWindow `gorm:"serializer:json" json:"quiet_hours"`
+	MaxEventsPerSecond int           `gorm:"default:100" json:"max_events_per_second"`
+	RateCapPolicy      RateCapPolicy `gorm:"default:drop" json:"rate_cap_policy"`
+	AutoResume         *bool         `gorm:"default:true" json:"auto_resume"`
+	RunState           *RunState     `gorm:"serializer:json" json:"run_state"` // Running simulation, nil when stopped
+}
+
+// defaultSettings are the settings before any were stored.
+func defaultSettings() Settings {
+	return Settings{MaxEventsPerSecond: defaultMaxEventsPerSecond, RateCapPolicy: RateCapDrop, AutoResume: boolPtr(true)}
+}
+
+// autoResume reports whether a simulation running at shutdown restarts on the next start.
+func (s Settings) autoResume() bool {
+	return s.AutoResume == nil || *s.AutoResume
+}
+
+// loadSettings reads the stored settings, the defaults when none are stored.
+func (eva *EvaApplication) loadSettings() (Settings, error) {
+	var settings []Settings
+	if err := eva.db.Limit(1).Find(&settings).Error; err != nil {
+		return Settings{}, err
+	}
+	if len(settings) == 0 {
+		return defaultSettings(), nil
+	}
+	return settings[0], nil
+}
+
+// updateSettings applies change to the stored settings and saves them. Updates are serialized so
+// concurrent changes of different settings do not overwrite each other.
+func (eva *EvaApplication) updateSettings(change func(*Settings)) (Settings, error) {
+	eva.settingsMu.Lock()
+	defer eva.settingsMu.Unlock()
+	settings, err := eva.loadSettings()
+	if err != nil {
+		return Settings{}, err
+	}
+	change(&settings)
+	return settings, eva.db.Save(&settings).Error
+}
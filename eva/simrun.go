@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// simulationRun is a single run of the simulation with its own context, goroutines and run
+// settings. StartSimulation creates it and StopSimulation tears it down, so nothing of a run
+// outlives it.
+type simulationRun struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup // Goroutines of the run
+	sched      *scheduler
+	ramp       ramp
+	stopsAt    time.Time // When the run stops itself, zero without a duration
+	noOffset   bool      // Interval events start without a random phase offset
+	seed       *int64    // Run seed of all randomness, nil for the global source
+	startedAt  time.Time // When the run started, before a restart for a resumed run
+	resumed    bool      // The run resumes one that was running before a restart
+	autoStart  bool      // Only auto-start events run, guarded by eva.mu
+	warmupEnds time.Time // First fires wait until then, zero without a warm-up
+	recordID   uint      // ID of the history entry of the run, zero when it could not be written
+}
+
+// newSimulationRun creates a run with the given settings. Its goroutines are not started yet.
+func newSimulationRun(opts RunOptions) *simulationRun {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &simulationRun{ctx: ctx, cancel: cancel, sched: newScheduler(), ramp: opts.Ramp, noOffset: opts.NoOffset, seed: opts.Seed}
+	run.startedAt = time.Now()
+	run.ramp.startedAt = run.startedAt
+	if opts.Warmup > 0 {
+		run.warmupEnds = run.startedAt.Add(opts.Warmup)
+		run.ramp.startedAt = run.warmupEnds
+	}
+	if !opts.StartedAt.IsZero() {
+		run.startedAt = opts.StartedAt
+		run.resumed = true
+	}
+	if opts.Duration > 0 {
+		run.stopsAt = time.Now().Add(opts.Duration)
+	}
+	return run
+}
+
+// goRun runs f in a goroutine of the run.
+func (r *simulationRun) goRun(f func(ctx context.Context)) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		f(r.ctx)
+	}()
+}
+
+// stop cancels the run and waits for its goroutines.
+func (r *simulationRun) stop() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// settledGoroutines waits up to a second for the goroutine count to drop to want and returns
+// the last count.
+func settledGoroutines(want int) int {
+	deadline := time.Now().Add(time.Second)
+	n := runtime.NumGoroutine()
+	for n > want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	return n
+}
+
+// startStop starts a simulation run, lets it fire for a moment and stops it.
+func startStop(t *testing.T, eva *EvaApplication, opts RunOptions) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panic: %v", r)
+		}
+	}()
+	if err := eva.StartSimulation(opts); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	eva.StopSimulation(StopManual, defaultDrainTimeout)
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	eva, _ := newTestEva(t)
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panic: %v", r)
+		}
+	}()
+	if report := eva.StopSimulation(StopManual, defaultDrainTimeout); report != (DrainReport{}) {
+		t.Errorf("got drain report %+v without a run", report)
+	}
+	eva.StopSimulation(StopShutdown, shutdownDrainTimeout)
+}
+
+func TestStartStopCyclesDoNotLeak(t *testing.T) {
+	eva, platform := newTestEva(t)
+	eva.events = []*EvaEvent{
+		testIntervalEvent(eva, 1, 10*time.Millisecond),
+		testIntervalEvent(eva, 2, 25*time.Millisecond),
+	}
+	opts := RunOptions{TimeScale: 1}
+
+	// The first cycle opens the database connections and starts the runtime's helpers.
+	startStop(t, eva, opts)
+	baseline := runtime.NumGoroutine()
+
+	for range 50 {
+		startStop(t, eva, opts)
+		// A second stop of the same run does nothing.
+		eva.StopSimulation(StopManual, defaultDrainTimeout)
+	}
+
+	if n := settledGoroutines(baseline); n > baseline {
+		t.Errorf("%d goroutines after 50 start/stop cycles, %d before", n, baseline)
+	}
+	eva.mu.Lock()
+	running := eva.run != nil
+	eva.mu.Unlock()
+	if running {
+		t.Error("a run is left after the last stop")
+	}
+	if len(platform.sendsOf(1)) == 0 {
+		t.Error("the runs did not fire")
+	}
+}
+
+func TestMinimalEventStartsSimulation(t *testing.T) {
+	bodies := []string{
+		`{"name": "Minimal"}`,
+		`{"name": "Interval Without Use Interval", "interval_seconds": 1}`,
+		`{"name": "Stateless Omitted", "use_interval": true, "interval_ms": 10, "data_fields": [{"name": "Count", "value_type": "int"}]}`,
+	}
+	for _, body := range bodies {
+		eva, _ := newTestEva(t)
+		// Created like POST /events does, then loaded back like on startup.
+		var created EvaEvent
+		if err := json.Unmarshal([]byte(body), &created); err != nil {
+			t.Fatal(err)
+		}
+		if err := created.Validate(); err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		if len(created.defaultWarnings()) == 0 {
+			t.Errorf("%s: no warning about the omitted settings", body)
+		}
+		if err := eva.db.Create(&created).Error; err != nil {
+			t.Fatal(err)
+		}
+		var ev EvaEvent
+		if err := eva.db.First(&ev, created.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("%s: panic: %v", body, r)
+				}
+			}()
+			ev.SetupPlatformEvent(eva)
+			if !ev.PlatformEvent.Stateless {
+				t.Errorf("%s: declared stateful, want stateless by default", body)
+			}
+			ev.EventId = 1
+			eva.events = []*EvaEvent{&ev}
+			startStop(t, eva, RunOptions{TimeScale: 1})
+		}()
+	}
+}
+
+func TestStartDuringDrain(t *testing.T) {
+	eva, _ := newTestEva(t)
+	ev := testIntervalEvent(eva, 1, 0)
+	ev.UseInterval = boolPtr(false)
+	ev.Stateless = boolPtr(false)
+	ev.PulseDurationMs = 300
+	ev.DataFields = []DataFields{{Name: "Active", ValueType: BoolType}}
+	ev.SetupPlatformEvent(eva)
+	eva.events = []*EvaEvent{ev}
+
+	if err := eva.StartSimulation(RunOptions{TimeScale: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// The pending fall of the pulse keeps the stop draining.
+	eva.mu.Lock()
+	err := eva.triggerEvent(ev, sendOptions{})
+	eva.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		eva.StopSimulation(StopManual, 5*time.Second)
+		close(stopped)
+	}()
+	for {
+		eva.mu.Lock()
+		stopping := eva.stopping != nil
+		eva.mu.Unlock()
+		if stopping {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := eva.StartSimulation(RunOptions{TimeScale: 2}); !errors.Is(err, errSimulationStopping) {
+		t.Fatalf("start during the drain: got %v, want %v", err, errSimulationStopping)
+	}
+	if _, err := eva.importEvents(EventExport{}, ImportSkip); !errors.Is(err, errImportRunning) {
+		t.Fatalf("import during the drain: got %v, want %v", err, errImportRunning)
+	}
+	<-stopped
+	if ev.Active() {
+		t.Error("the event is left active after the stop")
+	}
+
+	if err := eva.StartSimulation(RunOptions{TimeScale: 2}); err != nil {
+		t.Fatalf("start after the stop: %v", err)
+	}
+	defer eva.StopSimulation(StopManual, 0)
+	if scale := eva.TimeScale(); scale != 2 {
+		t.Errorf("time scale of the new run is %v, want 2", scale)
+	}
+}
+
+func TestStopCancelsDelayedSends(t *testing.T) {
+	eva, platform := newTestEva(t)
+	eva.rateCap.configure(1, RateCapDelay)
+	ev := testIntervalEvent(eva, 1, 0)
+	ev.UseInterval = boolPtr(false)
+	eva.events = []*EvaEvent{ev}
+
+	if err := eva.StartSimulation(RunOptions{TimeScale: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// The second send is beyond the cap of one per second and waits for the next second.
+	eva.mu.Lock()
+	for range 2 {
+		if err := eva.triggerEvent(ev, sendOptions{}); err != nil {
+			eva.mu.Unlock()
+			t.Fatal(err)
+		}
+	}
+	eva.mu.Unlock()
+	if pending := eva.pendingDelayedSends(); pending != 1 {
+		t.Fatalf("%d delayed sends pending, want 1", pending)
+	}
+
+	report := eva.StopSimulation(StopManual, 0)
+	if report.Abandoned != 1 {
+		t.Errorf("drain abandoned %d sends, want the delayed one", report.Abandoned)
+	}
+	time.Sleep(maxRateCapDelay)
+	if sends := len(platform.sendsOf(1)); sends != 1 {
+		t.Errorf("event sent %d times, want 1: the delayed send went out after the stop", sends)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// scheduledStart is a simulation start armed for a future time.
+type scheduledStart struct {
+	startAt time.Time
+	timer   *time.Timer
+}
+
+// parseStartAt reads the RFC3339 "start_at" of a scheduled start from the query or JSON body.
+// It must lie in the future.
+func parseStartAt(c fiber.Ctx) (time.Time, error) {
+	value := fiber.Query[string](c, "start_at")
+	if value == "" && len(c.Body()) > 0 {
+		var body struct {
+			StartAt string `json:"start_at"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return time.Time{}, err
+		}
+		value = body.StartAt
+	}
+	if value == "" {
+		return time.Time{}, fmt.Errorf("start_at is required")
+	}
+	startAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("start_at must be an RFC3339 time: %v", err)
+	}
+	if !startAt.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("start_at must be in the future")
+	}
+	return startAt, nil
+}
+
+// scheduleStart arms a simulation start at startAt. Caller must hold eva.mu.
+func (eva *EvaApplication) scheduleStart(startAt time.Time, opts RunOptions) *scheduledStart {
+	scheduled := &scheduledStart{startAt: startAt}
+	scheduled.timer = time.AfterFunc(time.Until(startAt), func() {
+		eva.mu.Lock()
+		// A cancelled schedule is no longer armed.
+		if eva.scheduled != scheduled {
+			eva.mu.Unlock()
+			return
+		}
+		eva.scheduled = nil
+		eva.mu.Unlock()
+
+		if err := eva.StartSimulation(opts); err != nil {
+			eva.acapp.Syslog.Critf("Scheduled simulation start failed: %v", err)
+			return
+		}
+		eva.acapp.Syslog.Infof("Scheduled simulation started")
+	})
+	eva.scheduled = scheduled
+	return scheduled
+}
+
+// cancelScheduledStart disarms the scheduled simulation start and reports whether one was armed.
+// Caller must hold eva.mu.
+func (eva *EvaApplication) cancelScheduledStart() bool {
+	if eva.scheduled == nil {
+		return false
+	}
+	eva.scheduled.timer.Stop()
+	eva.scheduled = nil
+	return true
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/Cacsjep/goxis/pkg/axevent"
+)
+
+// EventSource declares a source key of an event, e.g. a "channel" with one value per sensor of a
+// multi-sensor camera. Action rules can then filter the event per source value.
+type EventSource struct {
+	Key      string   `json:"key"`
+	NiceName string   `json:"nice_name"`
+	Values   []string `json:"values"`
+}
+
+// validateSources checks the source keys and their values.
+func (e *EvaEvent) validateSources() []*FieldError {
+	var errs []*FieldError
+	keys := map[string]bool{}
+	for i := range e.DataFields {
+		for _, key := range e.DataFields[i].keys() {
+			keys[key] = true
+		}
+	}
+	for _, src := range e.Sources {
+		if !validKey.MatchString(src.Key) {
+			errs = append(errs, &FieldError{Field: src.Key, Message: "source key must start with a letter or underscore and contain only letters, digits and underscores"})
+			continue
+		}
+		if keys[src.Key] {
+			errs = append(errs, &FieldError{Field: src.Key, Message: fmt.Sprintf("source key %s is already used", src.Key)})
+		}
+		keys[src.Key] = true
+		if len(src.Values) == 0 {
+			errs = append(errs, &FieldError{Field: src.Key, Message: "sources require at least one value"})
+		}
+		for i, value := range src.Values {
+			if strings.TrimSpace(value) == "" {
+				errs = append(errs, &FieldError{Field: src.Key, Message: "source values must not be empty"})
+			} else if slices.Contains(src.Values[:i], value) {
+				errs = append(errs, &FieldError{Field: src.Key, Message: fmt.Sprintf("source value %s is listed twice", value)})
+			}
+		}
+	}
+	return errs
+}
+
+// sourceEntries returns the platform entries of the event's sources, declared with their first value.
+func (e *EvaEvent) sourceEntries() []*acapapp.EventEntry {
+	entries := make([]*acapapp.EventEntry, 0, len(e.Sources))
+	for i := range e.Sources {
+		src := &e.Sources[i]
+		isSource, isData := true, false
+		var niceName *string
+		if src.NiceName != "" {
+			niceName = &src.NiceName
+		}
+		entries = append(entries, &acapapp.EventEntry{
+			Key:         src.Key,
+			Value:       src.Values[0],
+			ValueType:   axevent.AXValueTypeString,
+			KeyNiceName: niceName,
+			IsData:      &isData,
+			IsSource:    &isSource,
+		})
+	}
+	return entries
+}
+
+// parseSourceSelection resolves a source query parameter. A plain value selects a value of the
+// first source, "key:value" a value of the named source.
+func (e *EvaEvent) parseSourceSelection(query string) (map[string]interface{}, error) {
+	if query == "" {
+		return nil, nil
+	}
+	if len(e.Sources) == 0 {
+		return nil, fmt.Errorf("event has no sources")
+	}
+	src := &e.Sources[0]
+	value := query
+	if key, v, ok := strings.Cut(query, ":"); ok {
+		src = nil
+		for i := range e.Sources {
+			if e.Sources[i].Key == key {
+				src = &e.Sources[i]
+			}
+		}
+		if src == nil {
+			return nil, fmt.Errorf("event has no source %s", key)
+		}
+		value = v
+	}
+	if !slices.Contains(src.Values, value) {
+		return nil, fmt.Errorf("%s is not a value of source %s", value, src.Key)
+	}
+	return map[string]interface{}{src.Key: value}, nil
+}
+
+// addSources sets every source key of kvmap to its selected value or a random one.
+// Caller must hold e.state.mu.
+func (e *EvaEvent) addSources(kvmap acapapp.KeyValueMap, selected map[string]interface{}) {
+	for _, src := range e.Sources {
+		if value, ok := selected[src.Key]; ok {
+			kvmap[src.Key] = value
+			continue
+		}
+		if len(src.Values) > 0 {
+			kvmap[src.Key] = RandomStringFromSlice(e.randFor(&DataFields{}), src.Values)
+		}
+	}
+}
+
+// pickSources picks a random value for every source of the event, nil without sources.
+func (e *EvaEvent) pickSources() map[string]interface{} {
+	if len(e.Sources) == 0 {
+		return nil
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	sources := acapapp.KeyValueMap{}
+	e.addSources(sources, nil)
+	return sources
+}
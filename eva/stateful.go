@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/Cacsjep/goxis/pkg/axevent"
+)
+
+// Stateful events (Stateless=false) are property events: a bool state field toggles between
+// active and inactive, and the camera keeps the last state until the next one is sent.
+
+// IsStateful reports whether the event is declared as a property event.
+func (e *EvaEvent) IsStateful() bool {
+	return e.Stateless != nil && !*e.Stateless
+}
+
+// stateKey returns the key of the bool field that carries the state: StateField when set,
+// otherwise the first bool field. Empty when the event has no state field.
+func (e *EvaEvent) stateKey() string {
+	if e.StateField != "" {
+		if field := e.fieldByKey(e.StateField); field != nil && field.ValueType == BoolType {
+			return field.SanitizedKey()
+		}
+		return ""
+	}
+	for i := range e.DataFields {
+		if e.DataFields[i].ValueType == BoolType {
+			return e.DataFields[i].SanitizedKey()
+		}
+	}
+	return ""
+}
+
+// initialActive returns the state a stateful event declares on registration.
+func (e *EvaEvent) initialActive() bool {
+	return e.InitialState != nil && *e.InitialState
+}
+
+// validateState checks the stateful settings of the event.
+func (e *EvaEvent) validateState() []*FieldError {
+	var errs []*FieldError
+	if e.StateField != "" {
+		field := e.fieldByKey(e.StateField)
+		if field == nil {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("state_field %q does not name a data field", e.StateField)})
+		} else if field.ValueType != BoolType {
+			errs = append(errs, &FieldError{Field: field.Name, Message: "state_field must be a bool field"})
+		}
+	}
+	if e.ActiveDurationSeconds < 0 {
+		errs = append(errs, &FieldError{Message: "active_duration_seconds must not be negative"})
+	}
+	if e.ActiveDurationSeconds > 0 && (!e.IsStateful() || e.stateKey() == "") {
+		errs = append(errs, &FieldError{Message: "active_duration_seconds requires a stateful event with a bool state field"})
+	}
+	if (e.InitialState != nil || (e.SendInitialState != nil && *e.SendInitialState) || (e.SendInactiveOnShutdown != nil && *e.SendInactiveOnShutdown)) && (!e.IsStateful() || e.stateKey() == "") {
+		errs = append(errs, &FieldError{Message: "initial_state, send_initial_state and send_inactive_on_shutdown require a stateful event with a bool state field"})
+	}
+	if e.SuppressUnchanged != nil && *e.SuppressUnchanged && (!e.IsStateful() || e.stateKey() == "") {
+		errs = append(errs, &FieldError{Message: "suppress_unchanged requires a stateful event with a bool state field"})
+	}
+	if e.PulseDurationMs < 0 {
+		errs = append(errs, &FieldError{Message: "pulse_duration_ms must not be negative"})
+	}
+	if e.PulseDurationMs > 0 && (!e.IsStateful() || e.stateKey() == "") {
+		errs = append(errs, &FieldError{Message: "pulse_duration_ms requires a stateful event with a bool state field"})
+	}
+	if e.PulseDurationMs > 0 && e.ActiveDurationSeconds > 0 {
+		errs = append(errs, &FieldError{Message: "pulse_duration_ms and active_duration_seconds cannot be combined"})
+	}
+	return errs
+}
+
+// BuildStateKeyValueMap generates a payload with the state field set to active and the
+// given source values.
+func (e *EvaEvent) BuildStateKeyValueMap(active bool, sources map[string]interface{}) acapapp.KeyValueMap {
+	overrides := map[string]interface{}{}
+	for key, value := range sources {
+		overrides[key] = value
+	}
+	if key := e.stateKey(); key != "" {
+		overrides[key] = active
+	}
+	return e.buildKeyValueMap(overrides)
+}
+
+// sendOptions tune a single send of an event.
+type sendOptions struct {
+	force    bool                   // Bypass SuppressUnchanged
+	uncapped bool                   // Bypass the global rate cap, for sends that must go out now
+	sources  map[string]interface{} // Selected source values and field overrides, the others are picked or generated
+}
+
+// sendPayload sends a payload built by build for ev, adds it to the active recording and passes
+// it to sent, which may be nil. Unless opts.uncapped, the global rate cap may drop the send with
+// errRateCapped, or delay it: the send is then queued for its slot and sendPayload returns nil
+// right away, so callers holding eva.mu are not blocked.
+func (eva *EvaApplication) sendPayload(ev *EvaEvent, opts sendOptions, build func() acapapp.KeyValueMap, sent func(acapapp.KeyValueMap)) error {
+	if opts.uncapped {
+		return eva.deliver(ev, build, sent)
+	}
+	wait, err := eva.capSend(ev)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		eva.queueDelayed(ev, wait, build, sent)
+		return nil
+	}
+	return eva.deliver(ev, build, sent)
+}
+
+// deliver sends a payload built by build for ev now, see sendPayload.
+func (eva *EvaApplication) deliver(ev *EvaEvent, build func() acapapp.KeyValueMap, sent func(acapapp.KeyValueMap)) error {
+	var payload acapapp.KeyValueMap
+	err := eva.sendEvent(ev.EventId, func() (*axevent.AXEvent, error) {
+		payload = build()
+		return ev.newAXEvent(payload)
+	})
+	if err == nil {
+		ev.recordSend(payload)
+		eva.runSends.add(ev)
+		eva.record(ev, payload)
+		if sent != nil {
+			sent(payload)
+		}
+	} else {
+		eva.runSends.fail(ev, err)
+	}
+	return err
+}
+
+// newAXEvent builds the platform event of payload. Declared entries missing from the payload,
+// such as fields left out by their condition, are not sent instead of failing the send.
+func (e *EvaEvent) newAXEvent(payload acapapp.KeyValueMap) (*axevent.AXEvent, error) {
+	declared := e.PlatformEvent
+	declared.Entries = nil
+	for _, entry := range e.PlatformEvent.Entries {
+		if _, ok := payload[entry.Key]; ok {
+			declared.Entries = append(declared.Entries, entry)
+		}
+	}
+	return declared.NewEvent(payload)
+}
+
+// recordSend remembers the payload that was just sent.
+func (e *EvaEvent) recordSend(payload acapapp.KeyValueMap) {
+	e.state.mu.Lock()
+	e.state.lastPayload = payload
+	e.state.lastSentAt = time.Now()
+	e.state.mu.Unlock()
+}
+
+// sendState sends ev with its state field set to active and records the new state once it was
+// sent, a failed send keeps the last state. With SuppressUnchanged the send is skipped when
+// active matches the last sent state, unless forced.
+func (eva *EvaApplication) sendState(ev *EvaEvent, active bool, opts sendOptions) error {
+	if !opts.force && ev.suppressState(active) {
+		return nil
+	}
+	return eva.sendPayload(ev, opts, func() acapapp.KeyValueMap {
+		return ev.BuildStateKeyValueMap(active, opts.sources)
+	}, func(payload acapapp.KeyValueMap) {
+		ev.recordState(active, payload)
+	})
+}
+
+// recordState remembers the state and payload that were just sent.
+func (e *EvaEvent) recordState(active bool, payload acapapp.KeyValueMap) {
+	e.state.mu.Lock()
+	e.state.active = active
+	e.state.payload = payload
+	e.state.sentAt = time.Now()
+	e.state.mu.Unlock()
+}
+
+// suppressState reports whether sending active would repeat the last sent state of an event
+// with SuppressUnchanged, and counts the skipped send.
+func (e *EvaEvent) suppressState(active bool) bool {
+	if e.SuppressUnchanged == nil || !*e.SuppressUnchanged || e.state == nil {
+		return false
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	if e.state.sentAt.IsZero() || e.state.active != active {
+		return false
+	}
+	e.state.skipped++
+	return true
+}
+
+// SuppressedCount returns how many state sends SuppressUnchanged skipped since the last reset.
+func (e *EvaEvent) SuppressedCount() int {
+	if e.state == nil {
+		return 0
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	return e.state.skipped
+}
+
+// Active returns the last state sent by a stateful event.
+func (e *EvaEvent) Active() bool {
+	active, _, _ := e.LastState()
+	return active
+}
+
+// LastState returns the last state sent by a stateful event with its payload and send time.
+// The payload is nil and the time zero before the first send.
+func (e *EvaEvent) LastState() (bool, acapapp.KeyValueMap, time.Time) {
+	if e.state == nil {
+		return false, nil, time.Time{}
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	return e.state.active, e.state.payload, e.state.sentAt
+}
+
+// triggerEvent sends ev once. Stateful events with a state field toggle their state instead
+// of resending the same one, or pulse when PulseDurationMs is set.
+func (eva *EvaApplication) triggerEvent(ev *EvaEvent, opts sendOptions) error {
+	if ev.pulseDuration() > 0 {
+		return eva.pulse(ev, opts)
+	}
+	if ev.IsStateful() && ev.stateKey() != "" {
+		return eva.sendState(ev, !ev.Active(), opts)
+	}
+	return eva.sendPayload(ev, opts, func() acapapp.KeyValueMap { return ev.buildKeyValueMap(opts.sources) }, nil)
+}
+
+// fireSimulated sends ev for one simulation interval. Stateful events with ActiveDurationSeconds
+// go active and the run falls them back to inactive after that duration, unless the event is
+// removed first. It returns when the fall is due, zero without one.
+func (eva *EvaApplication) fireSimulated(run *simulationRun, ev *EvaEvent) time.Time {
+	if ev.ActiveDurationSeconds <= 0 || !ev.IsStateful() || ev.stateKey() == "" {
+		eva.triggerEvent(ev, sendOptions{})
+		return time.Time{}
+	}
+	// The fall repeats the source of the rise.
+	sources := ev.pickSources()
+	eva.sendState(ev, true, sendOptions{sources: sources})
+	fallAt := run.sched.now().Add(eva.scaled(time.Duration(ev.ActiveDurationSeconds) * time.Second))
+	run.sched.add(&simJob{
+		eventID: ev.ID,
+		at:      fallAt,
+		fire: func(time.Time) (time.Time, bool) {
+			eva.sendState(ev, false, sendOptions{sources: sources})
+			return time.Time{}, false
+		},
+	}, false)
+	return fallAt
+}
+
+// sendLowStates sends the inactive state of every registered stateful event that was left active,
+// unless its SendLowOnStop is false. Errors are logged and do not stop the remaining events.
+func (eva *EvaApplication) sendLowStates() {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	for _, ev := range eva.events {
+		eva.sendLowState(ev)
+	}
+}
+
+// sendLowState sends the inactive state of ev if it is a registered stateful event left active,
+// unless its SendLowOnStop is false. Errors are logged.
+func (eva *EvaApplication) sendLowState(ev *EvaEvent) {
+	if ev.EventId == 0 || !ev.IsStateful() || ev.stateKey() == "" || !ev.Active() {
+		return
+	}
+	if ev.SendLowOnStop != nil && !*ev.SendLowOnStop {
+		return
+	}
+	// The stop relies on this send, so the rate cap must neither drop nor defer it.
+	if err := eva.sendState(ev, false, sendOptions{uncapped: true}); err != nil {
+		eva.acapp.Syslog.Critf("Failed to send inactive state of %s: %v", ev.Name, err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// TimeBand changes how an event behaves during a time of day, e.g. busy during opening hours
+// and near silent at night. Start and End are local "HH:MM" times; a band whose end is before its
+// start crosses midnight. Days lists the weekdays (SUN..SAT) the band starts on, empty means
+// every day.
+type TimeBand struct {
+	Name            string   `json:"name"`
+	Start           string   `json:"start"`
+	End             string   `json:"end"`
+	Days            []string `json:"days,omitempty"`
+	IntervalMs      int      `json:"interval_ms"`      // Replaces the interval, 0 keeps it
+	FireProbability *float64 `json:"fire_probability"` // Replaces the event's fire_probability
+	RangeMultiplier *float64 `json:"range_multiplier"` // Scales the random ranges of int and float fields
+}
+
+// contains reports whether the local time of now falls inside the band.
+func (b *TimeBand) contains(now time.Time) bool {
+	return inDailyWindow(b.Start, b.End, b.Days, now)
+}
+
+// minutesPerWeek is the length of the week that band spans are laid out on.
+const minutesPerWeek = 7 * 24 * 60
+
+// weekSpan is a part of a band in minutes since Sunday 00:00.
+type weekSpan struct{ from, to int }
+
+// spans lays the band out on the week, one span per day it starts on. A span running past the
+// end of Saturday wraps around to Sunday.
+func (b *TimeBand) spans(start, end int) []weekSpan {
+	if end <= start {
+		end += 24 * 60
+	}
+	var spans []weekSpan
+	for day, name := range cronFields[4].names[:7] {
+		if !onWeekdayName(b.Days, name) {
+			continue
+		}
+		from, to := day*24*60+start, day*24*60+end
+		if to > minutesPerWeek {
+			spans = append(spans, weekSpan{from, minutesPerWeek}, weekSpan{0, to - minutesPerWeek})
+			continue
+		}
+		spans = append(spans, weekSpan{from, to})
+	}
+	return spans
+}
+
+// overlapDay returns the weekday on which two span lists overlap first, empty when they do not.
+func overlapDay(a, b []weekSpan) string {
+	for _, x := range a {
+		for _, y := range b {
+			if x.from < y.to && y.from < x.to {
+				return cronFields[4].names[max(x.from, y.from)/(24*60)]
+			}
+		}
+	}
+	return ""
+}
+
+// label names the band in the simulation status.
+func (b *TimeBand) label() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return b.Start + "-" + b.End
+}
+
+// activeBand returns the first time band containing now, nil outside of all bands.
+func (e *EvaEvent) activeBand(now time.Time) *TimeBand {
+	for i := range e.TimeBands {
+		if e.TimeBands[i].contains(now) {
+			return &e.TimeBands[i]
+		}
+	}
+	return nil
+}
+
+// fireProbability returns the chance a simulated tick fires at now, 1 without a probability.
+func (e *EvaEvent) fireProbability(now time.Time) float64 {
+	if band := e.activeBand(now); band != nil && band.FireProbability != nil {
+		return *band.FireProbability
+	}
+	if e.FireProbability != nil {
+		return *e.FireProbability
+	}
+	return 1
+}
+
+// scale returns field with its random int and float ranges multiplied by the band's
+// RangeMultiplier. An int range with a step is left alone when no multiple of the step would
+// remain.
+func (b *TimeBand) scale(field *DataFields) *DataFields {
+	if b == nil || b.RangeMultiplier == nil || !field.UseRandom {
+		return field
+	}
+	m := *b.RangeMultiplier
+	scaled := *field
+	switch field.ValueType {
+	case IntType:
+		scaled.IntRandStart = int(math.Round(float64(field.IntRandStart) * m))
+		scaled.IntRandEnd = int(math.Round(float64(field.IntRandEnd) * m))
+		if field.IntRandStep > 1 {
+			if first, last := stepMultiples(scaled.IntRandStart, scaled.IntRandEnd, field.IntRandStep); first > last {
+				return field
+			}
+		}
+	case FloatType:
+		scaled.FloatRandStart = field.FloatRandStart * m
+		scaled.FloatRandEnd = field.FloatRandEnd * m
+	default:
+		return field
+	}
+	return &scaled
+}
+
+// validateTimeBands checks the band times, days and overrides, and that no two bands overlap
+// on the same day.
+func (e *EvaEvent) validateTimeBands() []*FieldError {
+	var errs []*FieldError
+	spans := make([][]weekSpan, len(e.TimeBands))
+	for i, b := range e.TimeBands {
+		fail := func(msg string) {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("time band %d: %s", i+1, msg)})
+		}
+		start, err := parseClock(b.Start)
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		end, err := parseClock(b.End)
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		if start == end {
+			fail("start and end must differ")
+		}
+		if err := validateWeekdays(b.Days); err != nil {
+			fail(err.Error())
+			continue
+		}
+		spans[i] = b.spans(start, end)
+		for j := range i {
+			if day := overlapDay(spans[j], spans[i]); day != "" {
+				fail(fmt.Sprintf("overlaps time band %d on %s", j+1, day))
+			}
+		}
+		if b.IntervalMs < 0 {
+			fail("interval_ms must not be negative")
+		} else if b.IntervalMs != 0 && b.IntervalMs < minIntervalMs {
+			fail(fmt.Sprintf("interval_ms must be at least %d", minIntervalMs))
+		}
+		if p := b.FireProbability; p != nil && (*p < 0 || *p > 1) {
+			fail("fire_probability must be between 0 and 1")
+		}
+		if m := b.RangeMultiplier; m != nil && *m < 0 {
+			fail("range_multiplier must not be negative")
+		}
+	}
+	return errs
+}
+
+// EffectiveConfig is how the simulation treats an event at a given moment.
+type EffectiveConfig struct {
+	At              time.Time `json:"at"`
+	Band            string    `json:"band,omitempty"`
+	IntervalMs      int64     `json:"interval_ms"` // 0 with a random interval
+	RandomInterval  bool      `json:"random_interval"`
+	FireProbability float64   `json:"fire_probability"`
+	RangeMultiplier float64   `json:"range_multiplier"`
+	Quiet           bool      `json:"quiet"` // Global or event quiet hours hold its sends
+}
+
+// effectiveConfig resolves the time band, interval, fire probability and quiet hours of ev at
+// now. Caller must hold eva.mu.
+func (eva *EvaApplication) effectiveConfig(ev *EvaEvent, now time.Time) EffectiveConfig {
+	config := EffectiveConfig{
+		At:              now,
+		IntervalMs:      ev.baseInterval().Milliseconds(),
+		RandomInterval:  ev.usesRandomInterval(),
+		FireProbability: ev.fireProbability(now),
+		RangeMultiplier: 1,
+		Quiet:           inQuietHours(eva.quietHours, now) || inQuietHours(ev.QuietHours, now),
+	}
+	band := ev.activeBand(now)
+	if band == nil {
+		if config.RandomInterval {
+			config.IntervalMs = 0
+		}
+		return config
+	}
+	config.Band = band.label()
+	if band.IntervalMs > 0 {
+		config.IntervalMs = int64(band.IntervalMs)
+		config.RandomInterval = false
+	} else if config.RandomInterval {
+		config.IntervalMs = 0
+	}
+	if band.RangeMultiplier != nil {
+		config.RangeMultiplier = *band.RangeMultiplier
+	}
+	return config
+}
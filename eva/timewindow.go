@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TimeWindow overrides the random range or string pool of a field during a time of day.
+// Start and End are local "HH:MM" times; a window whose end is before its start crosses midnight.
+type TimeWindow struct {
+	Start          string   `json:"start"`
+	End            string   `json:"end"`
+	IntRandStart   *int     `json:"int_rand_start"`
+	IntRandEnd     *int     `json:"int_rand_end"`
+	FloatRandStart *float64 `json:"float_rand_start"`
+	FloatRandEnd   *float64 `json:"float_rand_end"`
+	RandomStrings  []string `json:"random_strings"`
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// dailyBoundary returns the most recent local occurrence of clock ("HH:MM", default "00:00")
+// at or before now.
+func dailyBoundary(clock string, now time.Time) time.Time {
+	minutes, err := parseClock(clock)
+	if err != nil {
+		minutes = 0
+	}
+	boundary := time.Date(now.Year(), now.Month(), now.Day(), minutes/60, minutes%60, 0, 0, now.Location())
+	if boundary.After(now) {
+		boundary = boundary.AddDate(0, 0, -1)
+	}
+	return boundary
+}
+
+// weekday returns the name (SUN..SAT) of the weekday of t.
+func weekday(t time.Time) string {
+	return cronFields[4].names[t.Weekday()]
+}
+
+// onWeekday reports whether days lists the weekday of t, true for no days.
+func onWeekday(days []string, t time.Time) bool {
+	return onWeekdayName(days, weekday(t))
+}
+
+// onWeekdayName reports whether days lists the weekday name day, true for no days.
+func onWeekdayName(days []string, day string) bool {
+	return len(days) == 0 || slices.ContainsFunc(days, func(d string) bool { return strings.EqualFold(d, day) })
+}
+
+// validateWeekdays checks that days only names weekdays.
+func validateWeekdays(days []string) error {
+	for _, day := range days {
+		if !slices.ContainsFunc(cronFields[4].names, func(name string) bool { return strings.EqualFold(name, day) }) {
+			return fmt.Errorf("unknown day %q, expected SUN..SAT", day)
+		}
+	}
+	return nil
+}
+
+// inDailyWindow reports whether the local time of now falls between the "HH:MM" times start and
+// end on one of days. A window whose end is before its start crosses midnight, and its part
+// after 00:00 belongs to the day it started on.
+func inDailyWindow(start, end string, days []string, now time.Time) bool {
+	from, err := parseClock(start)
+	if err != nil {
+		return false
+	}
+	to, err := parseClock(end)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if from <= to {
+		return minute >= from && minute < to && onWeekday(days, now)
+	}
+	if minute >= from {
+		return onWeekday(days, now)
+	}
+	return minute < to && onWeekday(days, now.AddDate(0, 0, -1))
+}
+
+// contains reports whether the local time of now falls inside the window.
+func (w *TimeWindow) contains(now time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// apply returns a copy of field with the window's overrides.
+func (w *TimeWindow) apply(field DataFields) DataFields {
+	if w.IntRandStart != nil {
+		field.IntRandStart = *w.IntRandStart
+	}
+	if w.IntRandEnd != nil {
+		field.IntRandEnd = *w.IntRandEnd
+	}
+	if w.FloatRandStart != nil {
+		field.FloatRandStart = *w.FloatRandStart
+	}
+	if w.FloatRandEnd != nil {
+		field.FloatRandEnd = *w.FloatRandEnd
+	}
+	if len(w.RandomStrings) > 0 {
+		field.RandomStrings = w.RandomStrings
+		// The base weights belong to the base pool, a window pool is picked uniformly.
+		field.RandomStringWeights = nil
+	}
+	return field
+}
+
+// atTime returns the field as configured for now: the first matching time window applied
+// to the base settings, or the field itself outside of all windows.
+func (d *DataFields) atTime(now time.Time) *DataFields {
+	for i := range d.TimeWindows {
+		if d.TimeWindows[i].contains(now) {
+			field := d.TimeWindows[i].apply(*d)
+			return &field
+		}
+	}
+	return d
+}
+
+// validateTimeWindows checks the window times and that every window yields valid ranges.
+func (d *DataFields) validateTimeWindows() []string {
+	var problems []string
+	for i := range d.TimeWindows {
+		w := &d.TimeWindows[i]
+		start, err := parseClock(w.Start)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("time window %d: %v", i+1, err))
+			continue
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("time window %d: %v", i+1, err))
+			continue
+		}
+		if start == end {
+			problems = append(problems, fmt.Sprintf("time window %d: start and end must differ", i+1))
+		}
+		field := w.apply(*d)
+		if (field.ValueType == IntType && field.IntRandStart > field.IntRandEnd) || (field.ValueType == FloatType && field.FloatRandStart > field.FloatRandEnd) {
+			problems = append(problems, fmt.Sprintf("time window %d: range start must not exceed its end", i+1))
+		}
+		if field.ValueType == EnumType {
+			for _, s := range w.RandomStrings {
+				if !slices.Contains(field.EnumValues, s) {
+					problems = append(problems, fmt.Sprintf("time window %d: random string %s is not an allowed value", i+1, s))
+				}
+			}
+		}
+	}
+	return problems
+}
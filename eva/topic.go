@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/Cacsjep/goxis/pkg/axevent"
+)
+
+// TopicLevel is one level of a custom event topic, e.g. tnsaxis:CameraApplicationPlatform.
+// Events with Topics are declared under topic0, topic1, ... instead of the fixed
+// CameraApplicationPlatform/<app>/<event> topic of the ACAP.
+type TopicLevel struct {
+	Namespace string `json:"namespace"` // Defaults to tnsaxis
+	Name      string `json:"name"`
+	NiceName  string `json:"nice_name"`
+}
+
+// maxTopicLevels bounds the depth of a custom topic.
+const maxTopicLevels = 4
+
+// validTopicName matches the names accepted for a topic level.
+var validTopicName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// validNamespace matches a topic namespace prefix.
+var validNamespace = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// namespace returns the namespace of the level, tnsaxis when unset.
+func (t *TopicLevel) namespace() string {
+	if t.Namespace == "" {
+		return axevent.OnfivNameSpaceTnsAxis
+	}
+	return t.Namespace
+}
+
+// validateTopics checks the custom topic levels of the event.
+func (e *EvaEvent) validateTopics() []*FieldError {
+	if len(e.Topics) == 0 {
+		return nil
+	}
+	var errs []*FieldError
+	if len(e.Topics) > maxTopicLevels {
+		errs = append(errs, &FieldError{Message: fmt.Sprintf("topics may have at most %d levels", maxTopicLevels)})
+	}
+	for i, level := range e.Topics {
+		if !validTopicName.MatchString(level.Name) {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("topic%d: name %q must start with a letter or underscore and contain only letters, digits, '_', '-' and '.'", i, level.Name)})
+		}
+		if level.Namespace != "" && !validNamespace.MatchString(level.Namespace) {
+			errs = append(errs, &FieldError{Message: fmt.Sprintf("topic%d: invalid namespace %q", i, level.Namespace)})
+		}
+	}
+	return errs
+}
+
+// ownDeclaration reports whether the event is declared by Eva itself instead of goxis, which
+// always declares under CameraApplicationPlatform and prefixes the nice name with the app name.
+func (e *EvaEvent) ownDeclaration() bool {
+	return len(e.Topics) > 0 || e.NiceName != ""
+}
+
+// topicLevels returns the custom topic levels, or the default CameraApplicationPlatform/<app>/<event>
+// topic. NiceName, when set, labels the last level verbatim.
+func (e *EvaEvent) topicLevels(appName string) []TopicLevel {
+	levels := []TopicLevel{
+		{Name: "CameraApplicationPlatform"},
+		{Name: appName},
+		{Name: sanitizeEventName(e.Name)},
+	}
+	if len(e.Topics) > 0 {
+		levels = slices.Clone(e.Topics)
+	}
+	if e.NiceName != "" {
+		levels[len(levels)-1].NiceName = e.NiceName
+	}
+	return levels
+}
+
+// topicPath joins topic levels into a namespace:name/... path.
+func topicPath(levels []TopicLevel) string {
+	path := ""
+	for i := range levels {
+		if i > 0 {
+			path += "/"
+		}
+		path += levels[i].namespace() + ":" + levels[i].Name
+	}
+	return path
+}
+
+// topicNamespaces maps the well-known topic namespace prefixes to their URIs.
+var topicNamespaces = map[string]string{
+	axevent.OnfivNameSpaceTns1:    "http://www.onvif.org/ver10/topics",
+	axevent.OnfivNameSpaceTnsAxis: "http://www.axis.com/2009/event/topics",
+}
+
+// TopicKey describes a declared key of an event topic.
+type TopicKey struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	Role string `json:"role"`
+}
+
+// TopicInfo describes how to subscribe to an event over ONVIF.
+type TopicInfo struct {
+	Event           string            `json:"event"`
+	Topic           string            `json:"topic"`
+	Namespaces      map[string]string `json:"namespaces"`
+	Keys            []TopicKey        `json:"keys"`
+	TopicExpression string            `json:"topic_expression"`
+}
+
+// axValueTypeName returns the name of a declared value type.
+func axValueTypeName(t axevent.AXEventValueType) string {
+	switch t {
+	case axevent.AXValueTypeInt:
+		return "int"
+	case axevent.AXValueTypeDouble:
+		return "double"
+	case axevent.AXValueTypeBool:
+		return "bool"
+	case axevent.AXValueTypeString:
+		return "string"
+	}
+	return "element"
+}
+
+// topicInfo derives the topic of a set up event, using the same declaration as registration.
+func (e *EvaEvent) topicInfo(appName string) TopicInfo {
+	levels := e.topicLevels(appName)
+	info := TopicInfo{Event: e.Name, Topic: topicPath(levels), Namespaces: map[string]string{}, Keys: []TopicKey{}}
+	xmlns := ""
+	for _, level := range levels {
+		ns := level.namespace()
+		uri, known := topicNamespaces[ns]
+		if _, done := info.Namespaces[ns]; done || !known {
+			continue
+		}
+		info.Namespaces[ns] = uri
+		xmlns += fmt.Sprintf(` xmlns:%s="%s"`, ns, uri)
+	}
+	for _, entry := range e.PlatformEvent.Entries {
+		role := "data"
+		if entry.IsSource != nil && *entry.IsSource {
+			role = "source"
+		}
+		info.Keys = append(info.Keys, TopicKey{Key: entry.Key, Type: axValueTypeName(entry.ValueType), Role: role})
+	}
+	info.TopicExpression = fmt.Sprintf(`<wsnt:TopicExpression Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet"%s>%s</wsnt:TopicExpression>`, xmlns, info.Topic)
+	return info
+}
+
+// topicKeyValueSet builds the declaration of an event with custom topics, mirroring the
+// CameraApplicationPlatform declaration of goxis with the topic levels replaced.
+func topicKeyValueSet(topics []TopicLevel, cpe *acapapp.CameraPlatformEvent) (*axevent.AXEventKeyValueSet, error) {
+	kvs := axevent.NewAXEventKeyValueSet()
+	for i := range topics {
+		ns := topics[i].namespace()
+		if err := kvs.AddKeyValue(fmt.Sprintf("topic%d", i), &ns, topics[i].Name, axevent.AXValueTypeString); err != nil {
+			return nil, fmt.Errorf("topic%d %s:%s: %w", i, ns, topics[i].Name, err)
+		}
+		if topics[i].NiceName != "" {
+			if err := kvs.AddNiceNames(fmt.Sprintf("topic%d", i), &ns, nil, &topics[i].NiceName); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, entry := range cpe.Entries {
+		if err := kvs.AddKeyValue(entry.Key, entry.Namespace, entry.Value, entry.ValueType); err != nil {
+			return nil, fmt.Errorf("key %s: %w", entry.Key, err)
+		}
+		if entry.IsData != nil && *entry.IsData {
+			if err := kvs.MarkAsData(entry.Key, entry.Namespace); err != nil {
+				return nil, err
+			}
+		}
+		if entry.IsSource != nil && *entry.IsSource {
+			if err := kvs.MarkAsSource(entry.Key, entry.Namespace); err != nil {
+				return nil, err
+			}
+		}
+		if entry.KeyNiceName != nil || entry.ValueNiceName != nil {
+			if err := kvs.AddNiceNames(entry.Key, entry.Namespace, entry.KeyNiceName, entry.ValueNiceName); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return kvs, nil
+}
+
+// declareTopicEvent declares an event with custom topics or nice name directly with the event handler.
+func (eva *EvaApplication) declareTopicEvent(event *EvaEvent) (int, error) {
+	kvs, err := topicKeyValueSet(event.topicLevels(eva.acapp.Manifest.ACAPPackageConf.Setup.AppName), &event.PlatformEvent)
+	if err != nil {
+		return 0, err
+	}
+	defer kvs.Free()
+	return eva.acapp.EventHandler.Declare(kvs, event.PlatformEvent.Stateless, func(int, any) {}, nil)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// TriggerJob is a one-shot trigger scheduled for later.
+type TriggerJob struct {
+	ID       int       `json:"id"`
+	EventID  uint      `json:"event_id"`
+	Name     string    `json:"event"`
+	DueAt    time.Time `json:"due_at"`
+	FollowUp bool      `json:"follow_up,omitempty"` // Scheduled by a follow-up of another event
+	depth    int       // Follow-up chain length up to this job, 0 for a manual trigger
+	timer    *time.Timer
+}
+
+// parseTriggerDelay reads the delay of a trigger from the "delay" query parameter (a Go duration
+// such as "30s") or a JSON body with delay_seconds. Zero means fire now.
+func parseTriggerDelay(c fiber.Ctx) (time.Duration, error) {
+	if q := fiber.Query[string](c, "delay"); q != "" {
+		d, err := time.ParseDuration(q)
+		if err != nil {
+			return 0, fmt.Errorf("invalid delay %q, expected a duration like 30s", q)
+		}
+		if d < 0 {
+			return 0, fmt.Errorf("delay must not be negative")
+		}
+		return d, nil
+	}
+	if len(c.Body()) == 0 {
+		return 0, nil
+	}
+	var body struct {
+		DelaySeconds float64 `json:"delay_seconds"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return 0, err
+	}
+	if body.DelaySeconds < 0 {
+		return 0, fmt.Errorf("delay_seconds must not be negative")
+	}
+	return time.Duration(body.DelaySeconds * float64(time.Second)), nil
+}
+
+// scheduleTrigger fires ev once after delay and returns the pending job.
+func (eva *EvaApplication) scheduleTrigger(ev *EvaEvent, delay time.Duration, opts sendOptions) TriggerJob {
+	return eva.addTriggerJob(ev, delay, opts, 0)
+}
+
+// addTriggerJob fires ev once after delay, followed by its follow-ups, and returns the pending
+// job. depth is the follow-up chain length that scheduled it. When the job is due the event is
+// looked up again by its DB ID under eva.mu, so it sends the current definition.
+func (eva *EvaApplication) addTriggerJob(ev *EvaEvent, delay time.Duration, opts sendOptions, depth int) TriggerJob {
+	eva.jobMu.Lock()
+	defer eva.jobMu.Unlock()
+	eva.nextJobID++
+	job := &TriggerJob{ID: eva.nextJobID, EventID: ev.ID, Name: ev.Name, DueAt: time.Now().Add(delay), FollowUp: depth > 0, depth: depth}
+	job.timer = time.AfterFunc(delay, func() {
+		eva.jobMu.Lock()
+		// A cancelled job is no longer in the map.
+		if eva.triggerJobs[job.ID] != job {
+			eva.jobMu.Unlock()
+			return
+		}
+		delete(eva.triggerJobs, job.ID)
+		eva.jobMu.Unlock()
+		eva.mu.Lock()
+		defer eva.mu.Unlock()
+		// The event may have been edited, declared again or deleted since the job was scheduled.
+		registered := eva.findRegisteredEvent(job.EventID)
+		if registered == nil || registered.EventId == 0 {
+			eva.acapp.Syslog.Warnf("Delayed trigger of %s skipped, the event is no longer registered", job.Name)
+			return
+		}
+		if err := eva.triggerEvent(registered, opts); err != nil {
+			eva.acapp.Syslog.Critf("Delayed trigger of %s failed: %v", registered.Name, err)
+			return
+		}
+		if job.depth == 0 {
+			registered.recordTrigger()
+		}
+		eva.scheduleFollowUps(registered, job.depth)
+	})
+	if eva.triggerJobs == nil {
+		eva.triggerJobs = map[int]*TriggerJob{}
+	}
+	eva.triggerJobs[job.ID] = job
+	return *job
+}
+
+// TriggerJobs lists the pending one-shot triggers ordered by due time.
+func (eva *EvaApplication) TriggerJobs() []TriggerJob {
+	eva.jobMu.Lock()
+	defer eva.jobMu.Unlock()
+	jobs := make([]TriggerJob, 0, len(eva.triggerJobs))
+	for _, job := range eva.triggerJobs {
+		jobs = append(jobs, *job)
+	}
+	slices.SortFunc(jobs, func(a, b TriggerJob) int { return a.DueAt.Compare(b.DueAt) })
+	return jobs
+}
+
+// cancelTriggerJob cancels a pending job, false when it does not exist or already fired.
+func (eva *EvaApplication) cancelTriggerJob(id int) bool {
+	eva.jobMu.Lock()
+	defer eva.jobMu.Unlock()
+	job, ok := eva.triggerJobs[id]
+	if !ok {
+		return false
+	}
+	job.timer.Stop()
+	delete(eva.triggerJobs, id)
+	return true
+}
+
+// cancelFollowUps cancels the pending jobs scheduled by follow-ups and returns how many there were.
+func (eva *EvaApplication) cancelFollowUps() int {
+	eva.jobMu.Lock()
+	defer eva.jobMu.Unlock()
+	cancelled := 0
+	for id, job := range eva.triggerJobs {
+		if job.FollowUp {
+			job.timer.Stop()
+			delete(eva.triggerJobs, id)
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// cancelTriggerJobs cancels the pending jobs of the event with the given DB ID, or all jobs for dbID 0.
+func (eva *EvaApplication) cancelTriggerJobs(dbID uint) {
+	eva.jobMu.Lock()
+	defer eva.jobMu.Unlock()
+	for id, job := range eva.triggerJobs {
+		if dbID == 0 || job.EventID == dbID {
+			job.timer.Stop()
+			delete(eva.triggerJobs, id)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
+	"net"
+	"strconv"
 	"strings"
 )
 
@@ -9,21 +13,284 @@ func sanitizeEventName(name string) string {
 	return strings.ReplaceAll(strings.ToLower(name), " ", "")
 }
 
-func RandomFloatInRange(start, end float64) float64 {
-	return start + (end-start)*rand.Float64()
+// RandSource is the subset of *rand.Rand used by the random helpers. Seeded events pass
+// their own *rand.Rand; everything else uses GlobalRand.
+type RandSource interface {
+	Float64() float64
+	Intn(n int) int
+	NormFloat64() float64
 }
 
-func RandomIntInRange(start, end int) int {
-	return rand.Intn(end-start+1) + start
+// GlobalRand is a RandSource backed by the shared, concurrency-safe math/rand source.
+var GlobalRand RandSource = globalRand{}
+
+type globalRand struct{}
+
+func (globalRand) Float64() float64     { return rand.Float64() }
+func (globalRand) Intn(n int) int       { return rand.Intn(n) }
+func (globalRand) NormFloat64() float64 { return rand.NormFloat64() }
+
+func clamp(value, min, max float64) float64 {
+	return math.Min(math.Max(value, min), max)
+}
+
+// RoundTo rounds value to the given number of decimal places.
+func RoundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+func RandomFloatInRange(r RandSource, start, end float64) float64 {
+	return start + (end-start)*r.Float64()
+}
+
+func RandomIntInRange(r RandSource, start, end int) int {
+	return r.Intn(end-start+1) + start
+}
+
+// RandomPoisson draws from a Poisson distribution with mean lambda. Large lambdas use the
+// normal approximation, which is accurate there and avoids Knuth's O(lambda) loop.
+func RandomPoisson(r RandSource, lambda float64) int {
+	if lambda > 30 {
+		return max(0, int(math.Round(lambda+math.Sqrt(lambda)*r.NormFloat64())))
+	}
+	limit := math.Exp(-lambda)
+	k := 0
+	for p := r.Float64(); p > limit; p *= r.Float64() {
+		k++
+	}
+	return k
+}
+
+// RandomExponential draws from an exponential distribution with the given rate (mean 1/rate).
+func RandomExponential(r RandSource, rate float64) float64 {
+	return -math.Log(1-r.Float64()) / rate
+}
+
+// stepMultiples returns the smallest and largest multiple of step within [start, end].
+// first > last when the range contains none.
+func stepMultiples(start, end, step int) (first, last int) {
+	first = int(math.Ceil(float64(start)/float64(step))) * step
+	last = int(math.Floor(float64(end)/float64(step))) * step
+	return first, last
 }
 
-func RandomStringFromSlice(choices []string) string {
+// RandomIntStepInRange picks a uniform multiple of step within [start, end].
+func RandomIntStepInRange(r RandSource, start, end, step int) int {
+	first, last := stepMultiples(start, end, step)
+	return first + r.Intn((last-first)/step+1)*step
+}
+
+// NearestStepMultiple rounds value to the nearest multiple of step within [start, end].
+func NearestStepMultiple(value, start, end, step int) int {
+	first, last := stepMultiples(start, end, step)
+	rounded := int(math.Round(float64(value)/float64(step))) * step
+	return min(max(rounded, first), last)
+}
+
+// RandomNormalInRange draws from a normal distribution and clamps the result to [min, max].
+// A stddev of 0 (or less) always yields the mean.
+func RandomNormalInRange(r RandSource, mean, stddev, min, max float64) float64 {
+	value := mean
+	if stddev > 0 {
+		value = r.NormFloat64()*stddev + mean
+	}
+	return clamp(value, min, max)
+}
+
+// WaveformValue samples a periodic wave between min and max at elapsed seconds.
+// A sine starts at the midpoint and rises; a sawtooth ramps from min to max once per period.
+func WaveformValue(shape string, elapsed, period, min, max float64) float64 {
+	if period <= 0 {
+		return min
+	}
+	phase := math.Mod(elapsed, period) / period
+	switch shape {
+	case "sawtooth":
+		return min + (max-min)*phase
+	default:
+		return min + (max-min)*(0.5+0.5*math.Sin(2*math.Pi*phase))
+	}
+}
+
+func RandomStringFromSlice(r RandSource, choices []string) string {
 	if len(choices) == 0 {
 		return ""
 	}
-	return choices[rand.Intn(len(choices))]
+	return choices[r.Intn(len(choices))]
 }
 
-func RandomBool() bool {
-	return rand.Intn(2) == 0
+// RandomWeightedStringFromSlice picks a string with probability proportional to its weight.
+// It falls back to a uniform pick when weights are missing, mismatched or all zero.
+func RandomWeightedStringFromSlice(r RandSource, choices []string, weights []float64) string {
+	if len(weights) != len(choices) {
+		return RandomStringFromSlice(r, choices)
+	}
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return RandomStringFromSlice(r, choices)
+	}
+	pick := r.Float64() * total
+	for i, w := range weights {
+		if pick < w {
+			return choices[i]
+		}
+		pick -= w
+	}
+	return choices[len(choices)-1]
+}
+
+func RandomBool(r RandSource) bool {
+	return r.Intn(2) == 0
+}
+
+// RandomBoolWeighted returns true with probability p.
+func RandomBoolWeighted(r RandSource, p float64) bool {
+	return r.Float64() < p
+}
+
+// patternClasses maps the placeholder characters of a generator pattern to their charset.
+var patternClasses = map[byte]string{
+	'A': "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	'a': "abcdefghijklmnopqrstuvwxyz",
+	'9': "0123456789",
+	'*': "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+}
+
+// maxPatternRepeat bounds {n} so a typo cannot produce megabyte payloads.
+const maxPatternRepeat = 256
+
+// patternPart is one position of a parsed generator pattern, repeated count times.
+type patternPart struct {
+	charset string
+	count   int
+}
+
+// ParsePattern parses a generator pattern. A is an uppercase letter, a a lowercase letter,
+// 9 a digit and * any of those; \ escapes the next character and every other character is
+// literal. {n} repeats the previous position n times, e.g. "AAA-999" or "*{8}".
+func ParsePattern(pattern string) ([]patternPart, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern must not be empty")
+	}
+	var parts []patternPart
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(pattern) {
+				return nil, fmt.Errorf("pattern ends with an unfinished escape")
+			}
+			i++
+			parts = append(parts, patternPart{charset: pattern[i : i+1], count: 1})
+		case c == '{':
+			end := strings.IndexByte(pattern[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("missing } at position %d", i)
+			}
+			if len(parts) == 0 {
+				return nil, fmt.Errorf("repeat at position %d has nothing to repeat", i)
+			}
+			n, err := strconv.Atoi(pattern[i+1 : i+end])
+			if err != nil || n < 1 || n > maxPatternRepeat {
+				return nil, fmt.Errorf("invalid repeat %q, expected a count between 1 and %d", pattern[i:i+end+1], maxPatternRepeat)
+			}
+			parts[len(parts)-1].count = n
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("unexpected } at position %d", i)
+		default:
+			charset, ok := patternClasses[c]
+			if !ok {
+				charset = pattern[i : i+1]
+			}
+			parts = append(parts, patternPart{charset: charset, count: 1})
+		}
+	}
+	return parts, nil
+}
+
+// RandomPatternString generates a string matching the parsed pattern.
+func RandomPatternString(r RandSource, parts []patternPart) string {
+	var b strings.Builder
+	for _, part := range parts {
+		for i := 0; i < part.count; i++ {
+			b.WriteByte(part.charset[r.Intn(len(part.charset))])
+		}
+	}
+	return b.String()
+}
+
+// platePresets holds common license plate layouts per country, one is picked per plate.
+var platePresets = map[string][]string{
+	"AT": {"W-12345A", "GU-123AB", "L-1234AB"},
+	"DE": {"B-AB 1234", "HH-A 123", "MUC-AB 12"},
+	"US": {"1ABC234", "ABC-1234"},
+}
+
+// ExpandPlatePattern generates a license plate. pattern is a country preset (AT, DE, US) or an
+// example plate whose letters become random uppercase letters and digits random digits, e.g.
+// "AA-123-BB". Every other character is kept.
+func ExpandPlatePattern(r RandSource, pattern string) string {
+	if layouts, ok := platePresets[strings.ToUpper(pattern)]; ok {
+		pattern = layouts[r.Intn(len(layouts))]
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+			b.WriteByte(patternClasses['A'][r.Intn(26)])
+		case c >= '0' && c <= '9':
+			b.WriteByte(patternClasses['9'][r.Intn(10)])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// RandomMAC generates a random unicast MAC address, e.g. "3a:1f:07:c2:9e:44".
+func RandomMAC(r RandSource) string {
+	mac := make(net.HardwareAddr, 6)
+	for i := range mac {
+		mac[i] = byte(r.Intn(256))
+	}
+	mac[0] &^= 0x01 // Clear the multicast bit
+	return mac.String()
+}
+
+// parseIPv4CIDR parses an IPv4 subnet such as "192.168.0.0/24". An empty string is the whole
+// address space.
+func parseIPv4CIDR(cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		cidr = "0.0.0.0/0"
+	}
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 subnet", cidr)
+	}
+	return subnet, nil
+}
+
+// RandomIPv4 generates a random address inside subnet. Subnets larger than /31 skip the
+// network and broadcast addresses.
+func RandomIPv4(r RandSource, subnet *net.IPNet) string {
+	ones, bits := subnet.Mask.Size()
+	size := uint64(1) << (bits - ones)
+	var offset uint64
+	if size > 2 {
+		offset = 1 + uint64(r.Float64()*float64(size-2))
+	} else {
+		offset = uint64(r.Intn(int(size)))
+	}
+	base := subnet.IP.To4()
+	n := (uint64(base[0])<<24 | uint64(base[1])<<16 | uint64(base[2])<<8 | uint64(base[3])) + offset
+	return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).String()
 }
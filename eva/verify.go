@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/acapapp"
+	"github.com/Cacsjep/goxis/pkg/axevent"
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	defaultVerifyTimeout = 5 * time.Second  // How long a loopback verification waits for its own event
+	maxVerifyTimeout     = 30 * time.Second // Longer waits would hold the request for no use
+)
+
+var (
+	errVerifyTimeout       = errors.New("event was sent but not received")
+	errVerifyNotRegistered = errors.New("event not registered with platform")
+)
+
+// VerifyResult is the outcome of a loopback verification.
+type VerifyResult struct {
+	Event     string                 `json:"event"`
+	Topic     string                 `json:"topic"`
+	LatencyMs float64                `json:"latency_ms"`
+	Received  map[string]interface{} `json:"received"`
+
+	declarationID int  // Platform declaration of the event, reported on a timeout
+	stateless     bool // Whether the event is declared stateless, reported on a timeout
+}
+
+// parseVerifyTimeout reads the "timeout_ms" query parameter of a verification.
+func parseVerifyTimeout(c fiber.Ctx) (time.Duration, error) {
+	if c.Query("timeout_ms") == "" {
+		return defaultVerifyTimeout, nil
+	}
+	ms := fiber.Query[int](c, "timeout_ms", -1)
+	if ms <= 0 || time.Duration(ms)*time.Millisecond > maxVerifyTimeout {
+		return 0, fmt.Errorf("timeout_ms must be between 1 and %d", maxVerifyTimeout.Milliseconds())
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// topicSubscription builds a subscription filter matching the topic levels of an event.
+func topicSubscription(levels []TopicLevel) *axevent.AXEventKeyValueSet {
+	kvs := axevent.NewAXEventKeyValueSet()
+	for i := range levels {
+		ns := levels[i].namespace()
+		kvs.AddKeyValue(fmt.Sprintf("topic%d", i), &ns, levels[i].Name, axevent.AXValueTypeString)
+	}
+	return kvs
+}
+
+// receivedValues reads the declared entries from a received key value set.
+func receivedValues(entries []*acapapp.EventEntry, kvs *axevent.AXEventKeyValueSet) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, entry := range entries {
+		var value interface{}
+		var err error
+		switch entry.ValueType {
+		case axevent.AXValueTypeInt:
+			value, err = kvs.GetInteger(entry.Key, entry.Namespace)
+		case axevent.AXValueTypeDouble:
+			value, err = kvs.GetDouble(entry.Key, entry.Namespace)
+		case axevent.AXValueTypeBool:
+			value, err = kvs.GetBoolean(entry.Key, entry.Namespace)
+		case axevent.AXValueTypeString:
+			value, err = kvs.GetString(entry.Key, entry.Namespace)
+		default:
+			continue
+		}
+		if err != nil {
+			value = fmt.Sprintf("error: %v", err)
+		}
+		values[entry.Key] = value
+	}
+	return values
+}
+
+// verifyEvent subscribes to the topic of the registered event with the given DB ID, sends it once
+// and waits up to timeout for the event to come back. The returned result carries the topic also
+// on failure. The event is only read under eva.mu, which is not held while waiting.
+func (eva *EvaApplication) verifyEvent(dbID uint, timeout time.Duration) (VerifyResult, error) {
+	eva.mu.Lock()
+	ev := eva.findRegisteredEvent(dbID)
+	if ev == nil || ev.EventId == 0 {
+		eva.mu.Unlock()
+		return VerifyResult{}, errVerifyNotRegistered
+	}
+	levels := ev.topicLevels(eva.acapp.Manifest.ACAPPackageConf.Setup.AppName)
+	result := VerifyResult{Event: ev.Name, Topic: topicPath(levels), declarationID: ev.EventId, stateless: ev.PlatformEvent.Stateless}
+	entries := slices.Clone(ev.PlatformEvent.Entries)
+	eva.mu.Unlock()
+
+	var mu sync.Mutex
+	var sentAt time.Time
+	received := make(chan *axevent.Event, 1)
+	subscription, err := eva.acapp.EventHandler.OnEvent(topicSubscription(levels), func(e *axevent.Event) {
+		mu.Lock()
+		armed := !sentAt.IsZero()
+		mu.Unlock()
+		// Stateful events deliver their current state on subscription, only the trigger counts.
+		if !armed {
+			return
+		}
+		select {
+		case received <- e:
+		default:
+		}
+	})
+	if err != nil {
+		return result, fmt.Errorf("subscribe to %s: %w", result.Topic, err)
+	}
+	defer eva.acapp.EventHandler.Unsubscribe(subscription)
+
+	if err := eva.sendVerified(ev, func() {
+		mu.Lock()
+		sentAt = time.Now()
+		mu.Unlock()
+	}); err != nil {
+		return result, err
+	}
+
+	select {
+	case e := <-received:
+		result.LatencyMs = float64(time.Since(sentAt).Microseconds()) / 1000
+		result.Received = receivedValues(entries, e.Kvs)
+		return result, nil
+	case <-time.After(timeout):
+		return result, errVerifyTimeout
+	}
+}
+
+// sendVerified sends ev once for a verification, calling arm right before the send. A stateful
+// event repeats its current state instead of toggling it, so verifying leaves the VMS as it was;
+// pulses fall back on their own. It fails when ev was deleted or unregistered meanwhile.
+func (eva *EvaApplication) sendVerified(ev *EvaEvent, arm func()) error {
+	eva.mu.Lock()
+	defer eva.mu.Unlock()
+	if eva.findRegisteredEvent(ev.ID) != ev || ev.EventId == 0 {
+		return errVerifyNotRegistered
+	}
+	arm()
+	var err error
+	if ev.pulseDuration() == 0 && ev.IsStateful() && ev.stateKey() != "" {
+		err = eva.sendState(ev, ev.Active(), sendOptions{force: true})
+	} else {
+		err = eva.triggerEvent(ev, sendOptions{force: true})
+	}
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSendVerifiedKeepsState(t *testing.T) {
+	eva, platform := newTestEva(t)
+	ev := testIntervalEvent(eva, 1, 0)
+	ev.Stateless = boolPtr(false)
+	ev.DataFields = []DataFields{{Name: "Active", ValueType: BoolType}}
+	ev.SetupPlatformEvent(eva)
+	ev.EventId = 1
+	eva.events = []*EvaEvent{ev}
+
+	for _, active := range []bool{true, false} {
+		eva.mu.Lock()
+		err := eva.sendState(ev, active, sendOptions{force: true})
+		eva.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		armed := false
+		if err := eva.sendVerified(ev, func() { armed = true }); err != nil {
+			t.Fatal(err)
+		}
+		if !armed {
+			t.Error("the verification was not armed before the send")
+		}
+		if ev.Active() != active {
+			t.Errorf("verifying an event with state %v toggled it", active)
+		}
+	}
+	if sends := len(platform.sendsOf(1)); sends != 4 {
+		t.Errorf("%d sends, want 4", sends)
+	}
+
+	// The event may be deleted while the verification subscribes.
+	eva.events = nil
+	if err := eva.sendVerified(ev, func() {}); !errors.Is(err, errVerifyNotRegistered) {
+		t.Errorf("verifying a deleted event: got %v, want %v", err, errVerifyNotRegistered)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// maxWarmup is the longest accepted warm-up of a simulation start.
+const maxWarmup = time.Hour
+
+// parseWarmup reads the optional "warmup_seconds" of a simulation start from the query or JSON
+// body. The warm-up delays the first fire of every event, it is not time-scaled.
+func parseWarmup(c fiber.Ctx) (time.Duration, error) {
+	seconds := fiber.Query[float64](c, "warmup_seconds")
+	if seconds == 0 && len(c.Body()) > 0 {
+		var body struct {
+			WarmupSeconds float64 `json:"warmup_seconds"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return 0, err
+		}
+		seconds = body.WarmupSeconds
+	}
+	if seconds < 0 || time.Duration(seconds*float64(time.Second)) > maxWarmup {
+		return 0, fmt.Errorf("warmup_seconds must be between 0 and %d", int(maxWarmup.Seconds()))
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// firstFireFrom returns when the first fire of an event joining the run is counted from: now,
+// or the end of the warm-up while it lasts. Phase offsets, clock alignment and cron schedules
+// apply from there.
+func (r *simulationRun) firstFireFrom() time.Time {
+	return later(r.warmupEnds, r.sched.now())
+}
+
+// warmupRemaining returns how much of the warm-up is left, zero once it ended.
+func (r *simulationRun) warmupRemaining(now time.Time) time.Duration {
+	return max(r.warmupEnds.Sub(now), 0)
+}